@@ -0,0 +1,214 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hysios/log"
+	"github.com/shirou/gopsutil/process"
+)
+
+// AdoptPollInterval how often an adopted process, which is not a child of
+// the manager, is checked for exit
+var AdoptPollInterval = time.Second
+
+// AttachProcess brings an existing pid under management, it is stopped and
+// restarted like any other process, a restart starts it as a child of the manager.
+// The output of an adopted run is not captured and its exit code is unknown,
+// its RunRecord is marked Adopted with exit code -1
+func (m *Manager) AttachProcess(id int) (*Process, error) {
+	if id == os.Getpid() {
+		return nil, fmt.Errorf("attach %d: the manager itself", id)
+	}
+
+	proc, err := process.NewProcess(int32(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if status, err := proc.Status(); err == nil && status == "Z" {
+		return nil, fmt.Errorf("attach %d: zombie process", id)
+	}
+
+	name, err := proc.Name()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := m.getProcess(name); ok {
+		return nil, fmt.Errorf("attach %d: %w: %s", id, ErrProcessExists, name)
+	}
+
+	binary, err := proc.Exe()
+	if err != nil {
+		return nil, err
+	}
+
+	var req = StartReq{Name: name, Binary: binary, Dir: name}
+	if args, err := proc.CmdlineSlice(); err == nil && len(args) > 1 {
+		req.Args = args[1:]
+	}
+
+	pproc, err := m.newProcess(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.adoptProcess(pproc, proc); err != nil {
+		return nil, err
+	}
+	m.process.Store(name, pproc)
+
+	return pproc, m.SaveConfig()
+}
+
+// findRunning looks up the pid file of proc left by a previous manager, and
+// returns the process if it still runs the same binary started at that time
+func (m *Manager) findRunning(pproc *Process) (*process.Process, bool) {
+	var (
+		cmd     = pproc.command()
+		pidfile = path.Join(cmd.Dir, pproc.fileName(".pid"))
+	)
+
+	info, err := os.Stat(pidfile)
+	if err != nil {
+		return nil, false
+	}
+
+	b, err := os.ReadFile(pidfile)
+	if err != nil {
+		return nil, false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, false
+	}
+
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return nil, false
+	}
+
+	if status, err := proc.Status(); err != nil || status == "Z" {
+		return nil, false
+	}
+
+	exe, err := proc.Exe()
+	if err != nil || !sameFile(exe, cmd.Path) {
+		return nil, false
+	}
+
+	// the pid file is written right after start, a process created later reuses the pid
+	createTime, err := proc.CreateTime()
+	if err != nil || time.Unix(0, createTime*int64(time.Millisecond)).After(info.ModTime().Add(time.Second)) {
+		return nil, false
+	}
+
+	return proc, true
+}
+
+func sameFile(a, b string) bool {
+	if ra, err := filepath.EvalSymlinks(a); err == nil {
+		a = ra
+	}
+	if rb, err := filepath.EvalSymlinks(b); err == nil {
+		b = rb
+	}
+	return a == b
+}
+
+// adoptProcess manages proc, a process not started by this manager, as the
+// current run of pproc, its output is not captured. It fails when proc can't
+// be moved into its cgroup
+func (m *Manager) adoptProcess(pproc *Process, proc *process.Process) error {
+	osproc, err := os.FindProcess(int(proc.Pid))
+	if err != nil {
+		return err
+	}
+
+	var (
+		cmd     = pproc.command()
+		done    = make(chan struct{})
+		startAt = time.Now()
+	)
+
+	if createTime, err := proc.CreateTime(); err == nil {
+		startAt = time.Unix(0, createTime*int64(time.Millisecond))
+	}
+
+	// like on start, a process out of its cgroup is not taken
+	if err := m.enterCgroup(pproc, int(proc.Pid)); err != nil {
+		return fmt.Errorf("cgroup: %w", err)
+	}
+
+	log.Infof("adopt process %s pid %d", pproc.Name, proc.Pid)
+	cmd.Process = osproc
+	pproc.setRun(proc, done)
+	pproc.setExitReason("")
+	pproc.loadHistory()
+	var (
+		outputFile = path.Join(cmd.Dir, pproc.fileName(".out"))
+		errorFile  = path.Join(cmd.Dir, pproc.fileName(".err"))
+		pidFile    = path.Join(cmd.Dir, pproc.fileName(".pid"))
+	)
+	unlock := pproc.lock()
+	pproc.OutputFile, pproc.ErrorFile, pproc.PidFile = outputFile, errorFile, pidFile
+	unlock()
+	if err := m.createPidfile(cmd, cmd.Dir, pproc.fileName(".pid")); err != nil {
+		return err
+	}
+
+	if len(pproc.HealthCheck.Kind()) > 0 {
+		pproc.setState(StateStarting)
+		pproc.health.Store(string(HealthStarting))
+		go m.watchHealth(pproc, cmd, done)
+	} else {
+		pproc.health.Store(string(HealthNone))
+		pproc.setState(StateRunning)
+	}
+
+	go func() {
+		// not our child, so there is no wait status, poll until it is gone
+		for {
+			running, err := proc.IsRunning()
+			if status, _ := proc.Status(); err != nil || !running || status == "Z" {
+				break
+			}
+			time.Sleep(AdoptPollInterval)
+		}
+
+		var status = &ExitStatus{Code: -1, Reason: ExitReasonExited}
+		if reason := pproc.exitReason.Load(); len(reason) > 0 {
+			status.Reason = reason
+			pproc.setExitReason("")
+		}
+
+		endAt := time.Now()
+		pproc.recordRun(RunRecord{
+			Pid:     proc.Pid,
+			StartAt: startAt,
+			EndAt:   endAt,
+			Runtime: endAt.Sub(startAt),
+			Exit:    *status,
+			Adopted: true,
+		})
+
+		current := pproc.isCurrent(cmd)
+		if current {
+			pproc.setExited(status)
+		}
+		close(done)
+		if !current {
+			return
+		}
+		m.processExit <- pproc
+	}()
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package process
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestManager_AdoptOnLoad(t *testing.T) {
+	first := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	first.ConfigFile = "./tmp/adopt.yaml"
+
+	proc, err := first.Start(StartReq{Name: "sleep", Args: []string{"10"}, Dir: "adopt"})
+	assert.NoError(t, err)
+
+	second := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+		Filename:  "./tmp/adopt.yaml",
+	})
+	defer second.Stop()
+	go second.Run()
+
+	adopted, ok := second.getProcess("sleep")
+	assert.True(t, ok)
+	assert.Equal(t, proc.Pid, adopted.Pid)
+	assert.Equal(t, StateRunning, adopted.State())
+
+	status, err := second.StopProcessWait("sleep")
+	assert.NoError(t, err)
+	assert.Equal(t, ExitReasonStopped, status.Reason)
+}
+
+func TestManager_AttachProcess(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	interval := AdoptPollInterval
+	AdoptPollInterval = 10 * time.Millisecond
+	defer func() { AdoptPollInterval = interval }()
+
+	cmd := exec.Command("sleep", "10")
+	assert.NoError(t, cmd.Start())
+	go cmd.Wait()
+
+	proc, err := manager.AttachProcess(cmd.Process.Pid)
+	assert.NoError(t, err)
+	assert.Equal(t, "sleep", proc.Name)
+	assert.Equal(t, []string{"10"}, proc.Args)
+
+	_, err = manager.AttachProcess(cmd.Process.Pid)
+	assert.Error(t, err)
+
+	_, err = manager.StopProcessWait("sleep")
+	assert.NoError(t, err)
+	assert.Equal(t, StateStopped, proc.State())
+	runs := proc.History()
+	assert.True(t, runs[len(runs)-1].Adopted)
+	assert.Equal(t, -1, runs[len(runs)-1].Exit.Code)
+
+	_, err = manager.AttachProcess(os.Getpid())
+	assert.Error(t, err)
+
+	// exited but not waited for
+	zombie := exec.Command("true")
+	assert.NoError(t, zombie.Start())
+	time.Sleep(100 * time.Millisecond)
+	_, err = manager.AttachProcess(zombie.Process.Pid)
+	assert.Error(t, err)
+	zombie.Wait()
+}
@@ -0,0 +1,261 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hysios/log"
+	"github.com/hysios/utils/convert"
+)
+
+// DefaultCPUPeriod the cpu.max period in microseconds
+var DefaultCPUPeriod = 100000
+
+// cgroupControllers enabled for the sub-trees of the processes
+var cgroupControllers = []string{"cpu", "memory", "pids", "io"}
+
+// CgroupConfig cgroup v2 limits of a process, they apply when the manager has
+// a CgroupParent, zero fields are left unlimited
+type CgroupConfig struct {
+	// MemoryMax bytes, like "512M" in config, the process is oom killed above
+	MemoryMax uint64
+	// MemoryHigh bytes the process is throttled and reclaimed above
+	MemoryHigh uint64
+	// CPUMax cores, like 1.5
+	CPUMax float64
+	// CPUWeight 1-10000, 100 by default
+	CPUWeight int
+	// PidsMax processes and threads
+	PidsMax int
+	// IOWeight 1-10000, 100 by default
+	IOWeight int
+}
+
+func loadCgroupConfig(v interface{}) CgroupConfig {
+	var cfg CgroupConfig
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return cfg
+	}
+
+	cfg.MemoryMax = toBytes(pm["MemoryMax"])
+	cfg.MemoryHigh = toBytes(pm["MemoryHigh"])
+	cfg.CPUMax, _ = convert.Float(pm["CPUMax"])
+	cfg.CPUWeight, _ = convert.Int(pm["CPUWeight"])
+	cfg.PidsMax, _ = convert.Int(pm["PidsMax"])
+	cfg.IOWeight, _ = convert.Int(pm["IOWeight"])
+
+	return cfg
+}
+
+// files the interface files of cfg and their values
+func (cfg CgroupConfig) files() map[string]string {
+	var files = make(map[string]string)
+
+	if cfg.MemoryMax > 0 {
+		files["memory.max"] = strconv.FormatUint(cfg.MemoryMax, 10)
+	}
+	if cfg.MemoryHigh > 0 {
+		files["memory.high"] = strconv.FormatUint(cfg.MemoryHigh, 10)
+	}
+	if cfg.CPUMax > 0 {
+		files["cpu.max"] = strconv.Itoa(int(cfg.CPUMax*float64(DefaultCPUPeriod))) + " " + strconv.Itoa(DefaultCPUPeriod)
+	}
+	if cfg.CPUWeight > 0 {
+		files["cpu.weight"] = strconv.Itoa(cfg.CPUWeight)
+	}
+	if cfg.PidsMax > 0 {
+		files["pids.max"] = strconv.Itoa(cfg.PidsMax)
+	}
+	if cfg.IOWeight > 0 {
+		files["io.weight"] = "default " + strconv.Itoa(cfg.IOWeight)
+	}
+	return files
+}
+
+// cgroup the cgroup of a process, its oom kill count is taken at each start
+// so an oom kill of the run can be told apart
+type cgroup struct {
+	mu       sync.Mutex
+	path     string
+	oomKills uint64
+}
+
+// cgroupPath the cgroup of proc under the parent of the manager
+func (m *Manager) cgroupPath(proc *Process) string {
+	return filepath.Join(m.CgroupParent, strings.ReplaceAll(proc.Name, "/", "_"))
+}
+
+// enterCgroup creates the cgroup of pproc with its limits and moves pid into
+// it, processes forked before the move stay in the cgroup of the manager
+func (m *Manager) enterCgroup(pproc *Process, pid int) error {
+	if len(m.CgroupParent) == 0 {
+		return nil
+	}
+
+	path, err := m.prepareCgroup(pproc)
+	if err != nil {
+		return err
+	}
+	return joinCgroup(path, pid)
+}
+
+// cgroupStart prepares the cgroup of pproc for a start of cmd. cmd is started
+// in it when the kernel allows, otherwise enter moves the process, which the
+// sandbox init holds until then, or which runs already without it. release
+// is called once cmd started
+func (m *Manager) cgroupStart(pproc *Process, cmd *exec.Cmd) (enter func(pid int) error, release func(), err error) {
+	release = func() {}
+	if len(m.CgroupParent) == 0 {
+		return nil, release, nil
+	}
+
+	path, err := m.prepareCgroup(pproc)
+	if err != nil {
+		return nil, release, err
+	}
+	if r, ok := startInCgroup(cmd, path); ok {
+		return nil, r, nil
+	}
+	return func(pid int) error { return joinCgroup(path, pid) }, release, nil
+}
+
+// prepareCgroup creates the cgroup of pproc with its limits and takes its oom
+// kill count, the path of the cgroup is returned
+func (m *Manager) prepareCgroup(pproc *Process) (string, error) {
+	var path = m.cgroupPath(pproc)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+
+	// the controllers are delegated to the sub-trees one by one, some may be unavailable
+	for _, controller := range cgroupControllers {
+		if err := writeCgroupFile(m.CgroupParent, "cgroup.subtree_control", "+"+controller); err != nil {
+			log.Errorf("enable cgroup controller %s error %s", controller, err)
+		}
+	}
+
+	for file, value := range pproc.Cgroup.files() {
+		if err := writeCgroupFile(path, file, value); err != nil {
+			return "", err
+		}
+	}
+
+	pproc.cgroup.mu.Lock()
+	pproc.cgroup.path = path
+	pproc.cgroup.oomKills = readCgroupKey(path, "memory.events", "oom_kill")
+	pproc.cgroup.mu.Unlock()
+
+	return path, nil
+}
+
+// joinCgroup moves pid into the cgroup at path
+func joinCgroup(path string, pid int) error {
+	return writeCgroupFile(path, "cgroup.procs", strconv.Itoa(pid))
+}
+
+// oomKilled reports whether a process of the cgroup was oom killed since the start
+func (c *cgroup) oomKilled() bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.path) > 0 && readCgroupKey(c.path, "memory.events", "oom_kill") > c.oomKills
+}
+
+// kill all processes of the cgroup, when the kernel supports cgroup.kill
+func (c *cgroup) kill() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.path) == 0 {
+		return nil
+	}
+	return writeCgroupFile(c.path, "cgroup.kill", "1")
+}
+
+// remove the cgroup, it must have no processes left
+func (c *cgroup) remove() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.path) == 0 {
+		return nil
+	}
+	err := os.Remove(c.path)
+	c.path = ""
+	return err
+}
+
+// usage the cgroup accounting of the whole sub-tree, exited processes included
+func (c *cgroup) usage(sample *MetricsSample) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.path) == 0 {
+		return
+	}
+
+	if usec := readCgroupKey(c.path, "cpu.stat", "usage_usec"); usec > 0 {
+		sample.CPUSeconds = float64(usec) / 1e6
+	}
+	if current, err := readCgroupValue(c.path, "memory.current"); err == nil {
+		sample.MemoryCurrent = current
+	}
+	if pids, err := readCgroupValue(c.path, "pids.current"); err == nil && pids > 0 {
+		sample.Processes = int(pids)
+	}
+}
+
+func writeCgroupFile(dir, file, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("write cgroup %s: %w", file, err)
+	}
+	return nil
+}
+
+// readCgroupValue reads a file holding a single number
+func readCgroupValue(dir, file string) (uint64, error) {
+	b, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// readCgroupKey reads the value of key from a flat keyed file like memory.events
+func readCgroupKey(dir, file, key string) uint64 {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.ParseUint(fields[1], 10, 64)
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,65 @@
+package process
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var (
+	cloneIntoCgroupOnce      sync.Once
+	cloneIntoCgroupSupported bool
+)
+
+// cloneIntoCgroup reports whether the kernel, linux 5.7 and later, starts
+// children right in a cgroup with CLONE_INTO_CGROUP
+func cloneIntoCgroup() bool {
+	cloneIntoCgroupOnce.Do(func() {
+		var uname unix.Utsname
+		if err := unix.Uname(&uname); err != nil {
+			return
+		}
+
+		release := strings.SplitN(unix.ByteSliceToString(uname.Release[:]), ".", 3)
+		if len(release) < 2 {
+			return
+		}
+		major, _ := strconv.Atoi(release[0])
+		minor, _ := strconv.Atoi(strings.TrimFunc(release[1], func(r rune) bool { return r < '0' || r > '9' }))
+		cloneIntoCgroupSupported = major > 5 || (major == 5 && minor >= 7)
+	})
+	return cloneIntoCgroupSupported
+}
+
+// startInCgroup makes cmd start in the cgroup at path, false when the kernel
+// or path, which has to be on the cgroup2 fs, does not allow it. release
+// closes the cgroup fd once cmd started
+func startInCgroup(cmd *exec.Cmd, path string) (release func(), ok bool) {
+	// the attr may be shared with the command of an earlier run
+	var attr syscall.SysProcAttr
+	if cmd.SysProcAttr != nil {
+		attr = *cmd.SysProcAttr
+	}
+	attr.UseCgroupFD, attr.CgroupFD = false, 0
+	cmd.SysProcAttr = &attr
+
+	if !cloneIntoCgroup() {
+		return nil, false
+	}
+
+	var fs unix.Statfs_t
+	if err := unix.Statfs(path, &fs); err != nil || fs.Type != unix.CGROUP2_SUPER_MAGIC {
+		return nil, false
+	}
+
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, false
+	}
+	attr.UseCgroupFD, attr.CgroupFD = true, fd
+	return func() { unix.Close(fd) }, true
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import "os/exec"
+
+// startInCgroup is linux only, the process is moved after its start
+func startInCgroup(cmd *exec.Cmd, path string) (release func(), ok bool) {
+	return nil, false
+}
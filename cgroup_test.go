@@ -0,0 +1,108 @@
+package process
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestCgroupConfig_Files(t *testing.T) {
+	files := loadCgroupConfig(map[string]interface{}{
+		"MemoryMax": "512M",
+		"CPUMax":    1.5,
+		"PidsMax":   64,
+		"IOWeight":  200,
+	}).files()
+
+	assert.Equal(t, map[string]string{
+		"memory.max": strconv.Itoa(512 << 20),
+		"cpu.max":    "150000 100000",
+		"pids.max":   "64",
+		"io.weight":  "default 200",
+	}, files)
+}
+
+func TestManager_Cgroup(t *testing.T) {
+	// a plain dir stands in for the delegated cgroup, the interface files are
+	// written as regular files
+	parent, err := os.MkdirTemp("", "cgroup")
+	assert.NoError(t, err)
+	defer os.RemoveAll(parent)
+
+	manager := NewManager(&ManagerConfig{
+		WorkerDir:    "./tmp",
+		CgroupParent: parent,
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:    "sleep",
+		Args:    []string{"10"},
+		Dir:     "cgroup",
+		Restart: RestartPolicy{Mode: RestartNever},
+		Cgroup:  CgroupConfig{MemoryMax: 64 << 20, PidsMax: 16},
+	})
+	assert.NoError(t, err)
+
+	var path = filepath.Join(parent, "sleep")
+	assert.Equal(t, path, proc.Snapshot().Cgroup)
+
+	// not on the cgroup2 fs, so the process was moved after its start
+	_, ok := startInCgroup(exec.Command("true"), path)
+	assert.False(t, ok)
+
+	procs, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+	assert.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(int(proc.Pid)), string(procs))
+
+	max, err := readCgroupValue(path, "memory.max")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(64<<20), max)
+
+	control, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(control), "+"))
+
+	// the kernel counting an oom kill
+	assert.NoError(t, os.WriteFile(filepath.Join(path, "memory.events"), []byte("oom 1\noom_kill 1\n"), 0644))
+	assert.NoError(t, proc.Kill())
+
+	select {
+	case <-proc.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("process not exited")
+	}
+	assert.Equal(t, ExitReasonOOM, proc.LastExit().Reason)
+
+	// a cgroup which can't be set up fails the start
+	assert.NoError(t, os.WriteFile(filepath.Join(parent, "broken"), nil, 0644))
+	_, err = manager.Start(StartReq{
+		Name:    "broken",
+		Binary:  "sleep",
+		Args:    []string{"10"},
+		Dir:     "cgroup",
+		Restart: RestartPolicy{Mode: RestartNever},
+	})
+	assert.Error(t, err)
+	_, ok = manager.getProcess("broken")
+	assert.False(t, ok)
+
+	// and the adopt of a process
+	assert.NoError(t, os.WriteFile(filepath.Join(parent, "sh"), nil, 0644))
+	cmd := exec.Command("sh", "-c", "sleep 10")
+	assert.NoError(t, cmd.Start())
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	_, err = manager.AttachProcess(cmd.Process.Pid)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cgroup")
+	_, ok = manager.getProcess("sh")
+	assert.False(t, ok)
+}
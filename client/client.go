@@ -3,6 +3,7 @@ package client
 import (
 	"encoding/gob"
 	"net/rpc"
+	"time"
 
 	"github.com/hysios/process"
 )
@@ -34,18 +35,24 @@ func Open(opt *ClientOption) (*Client, error) {
 	return &cli, nil
 }
 
-func (cli *Client) StartProcess(name string, fullbin string, args []string, env []string, dir string) (*process.Process, error) {
-	var (
-		reply process.Process
-		err   error
-	)
-	if err = cli.Call("Server.StartProcess", process.StartReq{
+func (cli *Client) StartProcess(name string, fullbin string, args []string, env []string, dir string) (*process.ProcessStatus, error) {
+	return cli.Start(process.StartReq{
 		Name:   name,
 		Binary: fullbin,
 		Args:   args,
 		Env:    env,
 		Dir:    dir,
-	}, &reply); err != nil {
+	})
+}
+
+// Start starts a process described by req, including its restart policy, and
+// returns its status
+func (cli *Client) Start(req process.StartReq) (*process.ProcessStatus, error) {
+	var (
+		reply process.ProcessStatus
+		err   error
+	)
+	if err = cli.Call("Server.StartProcess", req, &reply); err != nil {
 		return nil, err
 	}
 	return &reply, nil
@@ -74,6 +81,15 @@ func (cli *Client) LoadProcesses(filename string) error {
 	return nil
 }
 
+// AttachProcess brings an existing pid under management and returns its status
+func (cli *Client) AttachProcess(pid int) (*process.ProcessStatus, error) {
+	var reply process.ProcessStatus
+	if err := cli.Call("Server.AttachProcess", pid, &reply); err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
+
 func (cli *Client) StopProcess(name string) error {
 	if err := cli.Call("Server.StopProcess", name, nil); err != nil {
 		return err
@@ -81,6 +97,23 @@ func (cli *Client) StopProcess(name string) error {
 	return nil
 }
 
+// StopProcessWait stops a process and blocks until it exited
+func (cli *Client) StopProcessWait(name string) (*process.ExitStatus, error) {
+	var status process.ExitStatus
+	if err := cli.Call("Server.StopProcessWait", name, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// StopAll stops every process, dependants before their dependencies
+func (cli *Client) StopAll() error {
+	if err := cli.Call("Server.StopAll", 0, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (cli *Client) RemoveProcess(name string) error {
 	if err := cli.Call("Server.RemoveProcess", name, nil); err != nil {
 		return err
@@ -88,8 +121,146 @@ func (cli *Client) RemoveProcess(name string) error {
 	return nil
 }
 
-func (cli *Client) AllStatus() (map[string]interface{}, error) {
-	var processes = make(map[string]interface{})
+// Scale adds or removes instances of a cluster until it runs n instances
+func (cli *Client) Scale(name string, n int) error {
+	if err := cli.Call("Server.Scale", process.ScaleReq{Name: name, Instances: n}, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Reload restarts the instances of a cluster one at a time without downtime
+func (cli *Client) Reload(name string) error {
+	if err := cli.Call("Server.Reload", name, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// History the finished runs of a process, oldest first
+func (cli *Client) History(name string) ([]process.RunRecord, error) {
+	var history = make([]process.RunRecord, 0)
+	if err := cli.Call("Server.History", name, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// LogsPollWait how long a follow request waits on the server for new output
+var LogsPollWait = 10 * time.Second
+
+// Logs streams the output of a process, stdout and stderr lines tagged by
+// Stream, the last lines come first, with follow the new output follows until
+// the process is removed or the client is closed
+func (cli *Client) Logs(name string, follow bool, lines int) (<-chan process.LogLine, error) {
+	var reply process.LogsReply
+	if err := cli.Call("Server.Logs", process.LogsReq{Name: name, Lines: lines}, &reply); err != nil {
+		return nil, err
+	}
+
+	var ch = make(chan process.LogLine)
+	go func() {
+		defer close(ch)
+		for {
+			for _, line := range reply.Lines {
+				ch <- line
+			}
+
+			if !follow {
+				return
+			}
+
+			var req = process.LogsReq{Name: name, Since: reply.Next, Wait: LogsPollWait}
+			reply = process.LogsReply{}
+			if err := cli.Call("Server.Logs", req, &reply); err != nil {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// SearchLogs looks up the lines of the log files of a process, with next the
+// offset of the following page, 0 when there is none
+func (cli *Client) SearchLogs(req process.SearchLogsReq) ([]process.LogLine, int, error) {
+	var reply process.SearchLogsReply
+	if err := cli.Call("Server.SearchLogs", req, &reply); err != nil {
+		return nil, 0, err
+	}
+	return reply.Lines, reply.Next, nil
+}
+
+// Metrics the resource usage samples of a process taken after since, oldest
+// first, the last one is the current usage
+func (cli *Client) Metrics(name string, since time.Time) ([]process.MetricsSample, error) {
+	var samples = make([]process.MetricsSample, 0)
+	if err := cli.Call("Server.Metrics", process.MetricsReq{Name: name, Since: since}, &samples); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// Events streams the events of the processes, the ones kept by the server
+// come first, with follow the new events follow until the client is closed
+func (cli *Client) Events(follow bool) (<-chan process.Event, error) {
+	var reply process.EventsReply
+	if err := cli.Call("Server.Events", process.EventsReq{}, &reply); err != nil {
+		return nil, err
+	}
+
+	var ch = make(chan process.Event)
+	go func() {
+		defer close(ch)
+		for {
+			for _, event := range reply.Events {
+				ch <- event
+			}
+
+			if !follow {
+				return
+			}
+
+			var req = process.EventsReq{Since: reply.Next, Wait: LogsPollWait}
+			reply = process.EventsReply{}
+			if err := cli.Call("Server.Events", req, &reply); err != nil {
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// FlushLogs hands the buffered partial output lines of every process to their
+// consumers, marked partial
+func (cli *Client) FlushLogs() error {
+	if err := cli.Call("Server.FlushLogs", 0, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReopenLogs reopens the log files of every process, for an external logrotate
+func (cli *Client) ReopenLogs() error {
+	if err := cli.Call("Server.ReopenLogs", 0, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Describe the status of a process and its effective env, with the values of
+// the secret vars redacted
+func (cli *Client) Describe(name string) (*process.ProcessDescription, error) {
+	var desc process.ProcessDescription
+	if err := cli.Call("Server.Describe", name, &desc); err != nil {
+		return nil, err
+	}
+	return &desc, nil
+}
+
+func (cli *Client) AllStatus() ([]process.ProcessStatus, error) {
+	var processes = make([]process.ProcessStatus, 0)
 	if err := cli.Call("Server.AllStatus", 0, &processes); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,319 @@
+package process
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hysios/log"
+)
+
+// InstanceName the process name of instance i of a cluster
+func InstanceName(group string, i int) string {
+	return group + "-" + strconv.Itoa(i)
+}
+
+// newInstance builds instance i of the cluster described by req, with
+// INSTANCE_ID and PORT added to its env
+func (m *Manager) newInstance(req StartReq, i int) (*Process, error) {
+	var group = req.Name
+
+	if len(req.Binary) == 0 {
+		req.Binary = group
+	}
+
+	req.Name = InstanceName(group, i)
+	proc, err := m.newProcess(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the env of the definition is kept, the instance vars are added again on load
+	proc.cmd.Env = instanceEnv(proc.cmd.Env, req.Port, i)
+	proc.Group = group
+	proc.Instance = i
+	return proc, nil
+}
+
+// instanceEnv env with the INSTANCE_ID of instance i of a cluster, and its
+// PORT when the cluster has a base port
+func instanceEnv(env []string, port, i int) []string {
+	env = setEnv(env, "INSTANCE_ID", strconv.Itoa(i))
+	if port > 0 {
+		env = setEnv(env, "PORT", strconv.Itoa(port+i))
+	}
+	return env
+}
+
+// newInstances builds the instances from..to-1 of the cluster described by req
+func (m *Manager) newInstances(req StartReq, from, to int) ([]*Process, error) {
+	var procs = make([]*Process, 0, to-from)
+	for i := from; i < to; i++ {
+		proc, err := m.newInstance(req, i)
+		if err != nil {
+			return nil, err
+		}
+		procs = append(procs, proc)
+	}
+	return procs, nil
+}
+
+// startCluster starts req.Instances instances of req
+func (m *Manager) startCluster(req StartReq) ([]*Process, error) {
+	procs, err := m.newInstances(req, 0, req.Instances)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.runInstances(procs); err != nil {
+		return nil, err
+	}
+	return procs, m.SaveConfig()
+}
+
+// runInstances starts procs in order, when one fails the ones started before
+// are removed again
+func (m *Manager) runInstances(procs []*Process) error {
+	for i, proc := range procs {
+		err := m.waitDependencies(proc)
+		if err == nil {
+			_, err = m.runProcess(proc)
+		}
+		if err != nil {
+			for _, started := range procs[:i] {
+				if err := m.RemoveProcess(started.Name); err != nil {
+					log.Errorf("remove process %s error %s", started.Name, err)
+				}
+			}
+			return err
+		}
+		m.process.Store(proc.Name, proc)
+	}
+	return nil
+}
+
+// Instances the instances of a cluster ordered by instance id
+func (m *Manager) Instances(group string) []*Process {
+	var procs = make([]*Process, 0)
+	m.process.Range(func(key, value interface{}) bool {
+		if proc := value.(*Process); proc.Group == group {
+			procs = append(procs, proc)
+		}
+		return true
+	})
+
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].Instance < procs[j].Instance
+	})
+	return procs
+}
+
+// group the instances of the cluster name, a plain process called name
+// comes first as its instance 0
+func (m *Manager) group(name string) []*Process {
+	procs := m.Instances(name)
+	if proc, ok := m.getProcess(name); ok && len(proc.Group) == 0 {
+		procs = append([]*Process{proc}, procs...)
+	}
+	return procs
+}
+
+// Scale adds or removes instances of a cluster until it runs n instances, a
+// plain process is scaled as a cluster of one, the added instances are named
+// after it and it stays instance 0
+func (m *Manager) Scale(group string, n int) error {
+	if n < 1 {
+		return fmt.Errorf("scale %s to %d, at least one instance is required", group, n)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	procs := m.group(group)
+	if len(procs) == 0 {
+		return ErrProcessNotFound
+	}
+
+	var (
+		last = procs[len(procs)-1]
+		req  = last.request()
+	)
+	req.Name = group
+	req.Instances = n
+	log.Infof("scale %s from %d to %d instances", group, len(procs), n)
+
+	if n > len(procs) {
+		added, err := m.newInstances(req, last.Instance+1, last.Instance+1+n-len(procs))
+		if err != nil {
+			return err
+		}
+
+		if err := m.runInstances(added); err != nil {
+			return err
+		}
+	}
+
+	for _, proc := range procs {
+		if len(proc.Group) > 0 {
+			proc.Instances = n
+		}
+	}
+
+	for i := len(procs) - 1; i >= n; i-- {
+		if err := m.RemoveProcess(procs[i].Name); err != nil {
+			return err
+		}
+	}
+
+	return m.SaveConfig()
+}
+
+// request the definition proc was built from
+func (p *Process) request() StartReq {
+	return StartReq{
+		Name:                p.Name,
+		Binary:              p.Binary,
+		Args:                p.Args,
+		Env:                 p.Env,
+		EnvFile:             p.EnvFile,
+		EnvMode:             p.EnvMode,
+		EnvAllow:            p.EnvAllow,
+		Dir:                 p.Dir,
+		Restart:             p.Restart,
+		StopSignal:          p.StopSignal,
+		StopTimeout:         p.StopTimeout,
+		HealthCheck:         p.HealthCheck,
+		DependsOn:           p.DependsOn,
+		RestartOnDependency: p.RestartOnDependency,
+		Instances:           p.Instances,
+		Port:                p.Port,
+		Log:                 p.Log,
+		Triggers:            p.Triggers,
+		Thresholds:          p.Thresholds,
+		Cgroup:              p.Cgroup,
+		Credential:          p.Credential,
+		Tuning:              p.Tuning,
+		Sandbox:             p.Sandbox,
+		Security:            p.Security,
+	}
+}
+
+// DefaultReloadTimeout how long Reload waits for a replacement instance to become ready
+var DefaultReloadTimeout = 60 * time.Second
+
+// Reload replaces the instances of a cluster one at a time: a replacement is
+// started next to the old instance, which is stopped once the replacement is
+// ready, the reload aborts on the first replacement failing to come up, that
+// instance keeps running and the rest are left untouched
+func (m *Manager) Reload(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	procs := m.group(name)
+	if len(procs) == 0 {
+		return ErrProcessNotFound
+	}
+
+	for i, proc := range procs {
+		log.Infof("reload %s instance %d/%d", name, i+1, len(procs))
+		if err := m.replace(proc, DefaultReloadTimeout); err != nil {
+			return fmt.Errorf("reload %s aborted at %s: %w", name, proc.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// replace starts a replacement of proc and stops proc once the replacement
+// is ready, the replacement is stopped again when it is not ready in timeout
+func (m *Manager) replace(proc *Process, timeout time.Duration) error {
+	next, err := m.replacement(proc)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.runProcess(next); err != nil {
+		return err
+	}
+
+	if err := waitProcessReady(next, timeout); err != nil {
+		// roll back to proc, it never stopped
+		m.stopReplaced(next, ExitReasonStopped)
+		if cmd, done := proc.current(); done != nil {
+			if err := m.createPidfile(cmd, cmd.Dir, proc.fileName(".pid")); err != nil {
+				log.Errorf("restore pid file of %s error %s", proc.Name, err)
+			}
+		}
+		return err
+	}
+
+	m.process.Store(next.Name, next)
+	if err := m.SaveConfig(); err != nil {
+		log.Errorf("save config error %s", err)
+	}
+	m.stopReplaced(proc, ExitReasonRestarted)
+	m.cascadeRestart(next)
+	return nil
+}
+
+// replacement a process built from the definition of proc, it shares the
+// logs, history and metrics of proc and continues its restart count
+func (m *Manager) replacement(proc *Process) (*Process, error) {
+	var (
+		req  = proc.request()
+		next *Process
+		err  error
+	)
+	if len(proc.Group) > 0 {
+		req.Name = proc.Group
+		next, err = m.newInstance(req, proc.Instance)
+	} else {
+		next, err = m.newProcess(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	next.logs = proc.logs
+	next.history = proc.history
+	next.metrics = proc.metrics
+	next.state.restarts = proc.Snapshot().Restarts + 1
+	return next, nil
+}
+
+// stopReplaced stops proc, taken out of supervision, and waits for its exit,
+// its cgroup is spared, the other run of the reload shares it
+func (m *Manager) stopReplaced(proc *Process, reason string) {
+	cmd, done := proc.current()
+	proc.daemon.Store(0)
+	if done == nil {
+		// never started
+		proc.setState(StateStopped)
+		return
+	}
+
+	proc.setState(StateStopping)
+	proc.setExitReason(reason)
+	if err := m.terminateGroup(proc, cmd, done, nil); err != nil {
+		log.Errorf("stop process %s error %s", proc.Name, err)
+		return
+	}
+	<-done
+}
+
+// waitProcessReady blocks until proc is ready, it fails as soon as proc exits
+func waitProcessReady(proc *Process, timeout time.Duration) error {
+	var deadline = time.Now().Add(timeout)
+	for !proc.Ready() {
+		switch state := proc.State(); state {
+		case StateExited, StateBackoff, StateFatal, StateStopped:
+			return fmt.Errorf("process %s %s before it was ready", proc.Name, state)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("process %s not ready after %s", proc.Name, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
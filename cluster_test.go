@@ -0,0 +1,122 @@
+package process
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestManager_Scale(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	_, err := manager.Start(StartReq{
+		Name:      "sleep",
+		Args:      []string{"10"},
+		Dir:       "cluster",
+		Instances: 3,
+		Port:      8000,
+	})
+	assert.NoError(t, err)
+
+	procs := manager.Instances("sleep")
+	assert.Len(t, procs, 3)
+	assert.Equal(t, "sleep-2", procs[2].Name)
+	assert.Contains(t, procs[2].cmd.Env, "INSTANCE_ID=2")
+	assert.Contains(t, procs[2].cmd.Env, "PORT=8002")
+	assert.Equal(t, "tmp/cluster/sleep-2.pid", procs[2].PidFile)
+
+	assert.NoError(t, manager.Scale("sleep", 1))
+	assert.Len(t, manager.Instances("sleep"), 1)
+
+	assert.NoError(t, manager.Scale("sleep", 2))
+	procs = manager.Instances("sleep")
+	assert.Len(t, procs, 2)
+	assert.Equal(t, "sleep-1", procs[1].Name)
+	assert.Contains(t, procs[1].cmd.Env, "PORT=8001")
+
+	// instance 3 fails, instance 2 is removed again
+	assert.NoError(t, os.MkdirAll("./tmp/cluster/sleep-3.pid", 0755))
+	assert.Error(t, manager.Scale("sleep", 4))
+	assert.NoError(t, os.RemoveAll("./tmp/cluster/sleep-3.pid"))
+	procs = manager.Instances("sleep")
+	assert.Len(t, procs, 2)
+	assert.Equal(t, 2, procs[0].Instances)
+
+	// a plain process scales as instance 0 of its cluster
+	_, err = manager.Start(StartReq{
+		Name:   "sleep-plain",
+		Binary: "sleep",
+		Args:   []string{"10"},
+		Dir:    "cluster",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, manager.Scale("sleep-plain", 3))
+	procs = manager.group("sleep-plain")
+	assert.Len(t, procs, 3)
+	assert.Equal(t, "sleep-plain", procs[0].Name)
+	assert.Equal(t, "sleep-plain-2", procs[2].Name)
+	assert.Contains(t, procs[2].cmd.Env, "INSTANCE_ID=2")
+
+	assert.NoError(t, manager.Scale("sleep-plain", 1))
+	procs = manager.group("sleep-plain")
+	assert.Len(t, procs, 1)
+	assert.Equal(t, "sleep-plain", procs[0].Name)
+
+	assert.NoError(t, manager.StopAll())
+}
+
+func TestManager_Reload(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	_, err := manager.Start(StartReq{
+		Name:      "sleep",
+		Args:      []string{"10"},
+		Dir:       "reload",
+		Instances: 2,
+	})
+	assert.NoError(t, err)
+
+	var pids []int32
+	for _, proc := range manager.Instances("sleep") {
+		pids = append(pids, proc.Pid)
+	}
+
+	assert.NoError(t, manager.Reload("sleep"))
+	for i, proc := range manager.Instances("sleep") {
+		assert.NotEqual(t, pids[i], proc.Pid)
+		assert.True(t, proc.Ready())
+	}
+
+	// instances never become healthy, the reload stops at the first one and
+	// keeps it running
+	timeout := DefaultReloadTimeout
+	DefaultReloadTimeout = 300 * time.Millisecond
+	defer func() { DefaultReloadTimeout = timeout }()
+
+	procs := manager.Instances("sleep")
+	for _, proc := range procs {
+		proc.HealthCheck = HealthCheck{Command: []string{"false"}, Interval: time.Second}
+	}
+	pids = []int32{procs[0].Pid, procs[1].Pid}
+
+	err = manager.Reload("sleep")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "sleep-0")
+	assert.Equal(t, procs, manager.Instances("sleep"))
+	for i, proc := range procs {
+		assert.Equal(t, pids[i], proc.Pid)
+		assert.Equal(t, StateRunning, proc.State())
+	}
+
+	assert.NoError(t, manager.StopAll())
+}
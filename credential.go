@@ -0,0 +1,187 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hysios/log"
+	"github.com/hysios/utils/convert"
+)
+
+// Credential the user and groups a process runs as, names or numeric ids,
+// it runs as the manager user when User is empty
+type Credential struct {
+	User string
+	// Group the primary group, the group of User by default
+	Group string
+	// Groups the supplementary groups, the groups of User by default
+	Groups []string
+}
+
+func loadCredential(v interface{}) Credential {
+	var cred Credential
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return cred
+	}
+
+	cred.User, _ = pm["User"].(string)
+	cred.Group, _ = pm["Group"].(string)
+	cred.Groups, _ = convert.SliceString(pm["Groups"])
+
+	return cred
+}
+
+// resolvedCredential a Credential looked up in the user database
+type resolvedCredential struct {
+	uid      uint32
+	gid      uint32
+	groups   []uint32
+	username string
+	home     string
+}
+
+// resolve looks up the ids of cred, switching users requires root
+func (cred Credential) resolve() (*resolvedCredential, error) {
+	if len(cred.User) == 0 {
+		if len(cred.Group) > 0 || len(cred.Groups) > 0 {
+			return nil, fmt.Errorf("credential groups without a user")
+		}
+		return nil, nil
+	}
+
+	u, err := lookupUser(cred.User)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("user %s: invalid uid %s", cred.User, u.Uid)
+	}
+
+	var gidStr = u.Gid
+	if len(cred.Group) > 0 {
+		if gidStr, err = lookupGroupId(cred.Group); err != nil {
+			return nil, err
+		}
+	}
+	gid, err := strconv.ParseUint(gidStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("group %s: invalid gid %s", cred.Group, gidStr)
+	}
+
+	var groupIds = cred.Groups
+	if len(groupIds) == 0 {
+		// like a login of the user
+		groupIds, _ = u.GroupIds()
+	} else {
+		for i, group := range groupIds {
+			if groupIds[i], err = lookupGroupId(group); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var groups = make([]uint32, 0, len(groupIds))
+	for _, id := range groupIds {
+		n, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gid %s", id)
+		}
+		groups = append(groups, uint32(n))
+	}
+
+	if euid := os.Geteuid(); euid != 0 && uint64(euid) != uid {
+		return nil, fmt.Errorf("run as user %s requires root", cred.User)
+	}
+
+	return &resolvedCredential{
+		uid:      uint32(uid),
+		gid:      uint32(gid),
+		groups:   groups,
+		username: u.Username,
+		home:     u.HomeDir,
+	}, nil
+}
+
+// lookupUser looks up a user name or id, an id missing in the user database,
+// as usual in containers, is taken as is with a group of the same id
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		u, err := user.LookupId(name)
+		if _, ok := err.(user.UnknownUserIdError); ok {
+			return &user.User{Uid: name, Gid: name, Username: name, HomeDir: "/"}, nil
+		}
+		return u, err
+	}
+	return user.Lookup(name)
+}
+
+func lookupGroupId(name string) (string, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return name, nil
+	}
+
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return "", err
+	}
+	return g.Gid, nil
+}
+
+// credentialEnv env with the USER, LOGNAME and HOME of cred
+func credentialEnv(env []string, cred *resolvedCredential) []string {
+	env = setEnv(env, "USER", cred.username)
+	env = setEnv(env, "LOGNAME", cred.username)
+	return setEnv(env, "HOME", cred.home)
+}
+
+// setEnv sets key in env, replacing its earlier values
+func setEnv(env []string, key, value string) []string {
+	var out = make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, key+"=") {
+			out = append(out, kv)
+		}
+	}
+	return append(out, key+"="+value)
+}
+
+// validateCredentialDir checks dir, relative to the worker dir, is a subdir
+// of its own, the dir of a process running as another user is given to it
+func validateCredentialDir(dir string) error {
+	switch clean := path.Clean(dir); {
+	case clean == "." || clean == "/":
+		return fmt.Errorf("a process with a credential requires a dir of its own")
+	case clean == ".." || strings.HasPrefix(clean, "../"):
+		return fmt.Errorf("dir %s of a process with a credential leaves the worker dir", dir)
+	}
+	return nil
+}
+
+// chownDir gives dir and the files directly in it to the user of cred
+func chownDir(dir string, cred *resolvedCredential) {
+	var uid, gid = int(cred.uid), int(cred.gid)
+
+	if err := os.Lchown(dir, uid, gid); err != nil {
+		log.Errorf("chown %s error %s", dir, err)
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if err := os.Lchown(filepath.Join(dir, entry.Name()), uid, gid); err != nil {
+			log.Errorf("chown %s error %s", entry.Name(), err)
+		}
+	}
+}
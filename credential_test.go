@@ -0,0 +1,94 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestCredential_Resolve(t *testing.T) {
+	resolved, err := Credential{}.resolve()
+	assert.NoError(t, err)
+	assert.Nil(t, resolved)
+
+	_, err = Credential{Group: "root"}.resolve()
+	assert.Error(t, err)
+
+	_, err = Credential{User: "no-such-user-here"}.resolve()
+	assert.Error(t, err)
+
+	if os.Geteuid() != 0 {
+		_, err = Credential{User: "0"}.resolve()
+		assert.Error(t, err)
+		return
+	}
+
+	resolved, err = Credential{User: "nobody", Groups: []string{"0", "65534"}}.resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(65534), resolved.uid)
+	assert.Equal(t, []uint32{0, 65534}, resolved.groups)
+	assert.Equal(t, "nobody", resolved.username)
+
+	// an id without a passwd entry
+	resolved, err = Credential{User: "4242"}.resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(4242), resolved.uid)
+	assert.Equal(t, uint32(4242), resolved.gid)
+	assert.Equal(t, "/", resolved.home)
+}
+
+func TestValidateCredentialDir(t *testing.T) {
+	assert.NoError(t, validateCredentialDir("api"))
+	assert.NoError(t, validateCredentialDir("/srv/api"))
+	assert.Error(t, validateCredentialDir(""))
+	assert.Error(t, validateCredentialDir("api/.."))
+	assert.Error(t, validateCredentialDir("../api"))
+	assert.Error(t, validateCredentialDir("api/../../etc"))
+}
+
+func TestSetEnv(t *testing.T) {
+	assert.Equal(t, []string{"A=1", "HOME=/home/a"}, setEnv([]string{"HOME=/root", "A=1"}, "HOME", "/home/a"))
+}
+
+func TestManager_Credential(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching users requires root")
+	}
+
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:       "sh",
+		Args:       []string{"-c", "echo $(id -u) $(id -g) $USER $HOME"},
+		Dir:        "credential",
+		Restart:    RestartPolicy{Mode: RestartNever},
+		Credential: Credential{User: "nobody"},
+	})
+	assert.NoError(t, err)
+
+	select {
+	case <-proc.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("process not exited")
+	}
+
+	lines, _, err := manager.Logs("sh", 0, 1, 0)
+	assert.NoError(t, err)
+	assert.Len(t, lines, 1)
+	assert.True(t, strings.HasPrefix(lines[0].Line, "65534 65534 nobody "), lines[0].Line)
+
+	info, err := os.Stat("./tmp/credential")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(65534), info.Sys().(*syscall.Stat_t).Uid)
+}
@@ -0,0 +1,11 @@
+package process
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// setCredential fails, windows processes run as the manager user
+func setCredential(cmd *exec.Cmd, cred *resolvedCredential) error {
+	return errors.New("process credentials are not supported on windows")
+}
@@ -0,0 +1,211 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hysios/log"
+)
+
+// DefaultDependencyTimeout how long a process waits for its dependencies to become ready
+var DefaultDependencyTimeout = 30 * time.Second
+
+// sortProcesses orders procs so every process comes after the processes it
+// depends on, a dependency on a cluster means all of its instances,
+// dependencies outside procs are ignored
+func sortProcesses(procs []*Process) ([]*Process, error) {
+	var (
+		byName  = make(map[string][]*Process, len(procs))
+		visited = make(map[string]bool, len(procs))
+		path    = make([]string, 0)
+		sorted  = make([]*Process, 0, len(procs))
+		visit   func(proc *Process) error
+	)
+
+	for _, proc := range procs {
+		byName[proc.Name] = append(byName[proc.Name], proc)
+		if len(proc.Group) > 0 {
+			byName[proc.Group] = append(byName[proc.Group], proc)
+		}
+	}
+
+	visit = func(proc *Process) error {
+		for i, name := range path {
+			if name == proc.Name {
+				cycle := append(path[i:], proc.Name)
+				return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cycle, " -> "))
+			}
+		}
+
+		if visited[proc.Name] {
+			return nil
+		}
+
+		path = append(path, proc.Name)
+		for _, dep := range proc.DependsOn {
+			for _, depProc := range byName[dep] {
+				if err := visit(depProc); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+
+		visited[proc.Name] = true
+		sorted = append(sorted, proc)
+		return nil
+	}
+
+	for _, proc := range procs {
+		if err := visit(proc); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// waitDependencies blocks until every dependency of proc is ready, a
+// dependency stopped by the user fails with ErrDependencyStopped
+func (m *Manager) waitDependencies(proc *Process) error {
+	for _, dep := range proc.DependsOn {
+		if m.stoppedByUser(dep) {
+			return fmt.Errorf("process %s: dependency %s: %w", proc.Name, dep, ErrDependencyStopped)
+		}
+		if err := m.waitReady(dep, DefaultDependencyTimeout); err != nil {
+			return fmt.Errorf("process %s: %w", proc.Name, err)
+		}
+	}
+	return nil
+}
+
+// stoppedByUser reports whether the process, or every instance of the
+// cluster, name is stopped by the user
+func (m *Manager) stoppedByUser(name string) bool {
+	deps := m.Instances(name)
+	if dep, ok := m.getProcess(name); ok {
+		deps = []*Process{dep}
+	}
+	if len(deps) == 0 {
+		return false
+	}
+
+	for _, dep := range deps {
+		if !dep.stopped() {
+			return false
+		}
+	}
+	return true
+}
+
+// waitReady blocks until the process, or every instance of the cluster, is
+// running, and healthy when it has a health check
+func (m *Manager) waitReady(name string, timeout time.Duration) error {
+	var deadline = time.Now().Add(timeout)
+	for {
+		deps := m.Instances(name)
+		if dep, ok := m.getProcess(name); ok {
+			deps = []*Process{dep}
+		}
+		if len(deps) == 0 {
+			return fmt.Errorf("dependency %s: %w", name, ErrProcessNotFound)
+		}
+
+		var ready = true
+		for _, dep := range deps {
+			ready = ready && dep.Ready()
+		}
+		if ready {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("dependency %s not ready after %s", name, timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// dependants the processes declaring dep, or its cluster, in DependsOn
+func (m *Manager) dependants(dep *Process) []*Process {
+	var procs = make([]*Process, 0)
+	m.process.Range(func(key, value interface{}) bool {
+		proc := value.(*Process)
+		for _, name := range proc.DependsOn {
+			if name == dep.Name || (len(dep.Group) > 0 && name == dep.Group) {
+				procs = append(procs, proc)
+				break
+			}
+		}
+		return true
+	})
+	return procs
+}
+
+// cascadeRestart restarts the dependants of proc that asked for it, once proc is ready again
+func (m *Manager) cascadeRestart(proc *Process) {
+	for _, dependant := range m.dependants(proc) {
+		if !dependant.RestartOnDependency || dependant.daemon.Load() == 0 {
+			continue
+		}
+
+		go func(dependant *Process) {
+			if err := m.waitReady(proc.Name, DefaultDependencyTimeout); err != nil {
+				log.Errorf("restart dependant %s error %s", dependant.Name, err)
+				return
+			}
+			log.Infof("restart dependant %s of %s", dependant.Name, proc.Name)
+			if err := m.restartProcess(dependant); err != nil {
+				log.Errorf("restart dependant %s error %s", dependant.Name, err)
+			}
+		}(dependant)
+	}
+}
+
+// StopAll stops every process, dependants before their dependencies
+func (m *Manager) StopAll() error {
+	sorted, err := sortProcesses(m.Processes())
+	if err != nil {
+		return err
+	}
+
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if _, err := m.StopProcessWait(sorted[i].Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shutdown stops every running process in reverse dependency order and
+// waits for each to exit
+func (m *Manager) shutdown() {
+	procs := m.Processes()
+	if sorted, err := sortProcesses(procs); err == nil {
+		procs = sorted
+	}
+
+	for i := len(procs) - 1; i >= 0; i-- {
+		proc := procs[i]
+		proc.daemon.Store(0)
+
+		cmd, done := proc.current()
+		if done == nil {
+			continue
+		}
+		select {
+		case <-done:
+			continue
+		default:
+		}
+
+		proc.setState(StateStopping)
+		proc.setExitReason(ExitReasonStopped)
+		if err := m.terminate(proc, cmd, done); err != nil {
+			log.Errorf("stop process %s error %s", proc.Name, err)
+			continue
+		}
+		<-done
+	}
+}
@@ -0,0 +1,78 @@
+package process
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestSortProcesses(t *testing.T) {
+	procs := []*Process{
+		{Name: "worker", DependsOn: []string{"api"}},
+		{Name: "api", DependsOn: []string{"db", "external"}},
+		{Name: "db"},
+	}
+
+	sorted, err := sortProcesses(procs)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, proc := range sorted {
+		names = append(names, proc.Name)
+	}
+	assert.Equal(t, []string{"db", "api", "worker"}, names)
+}
+
+func TestSortProcesses_Cycle(t *testing.T) {
+	procs := []*Process{
+		{Name: "a", DependsOn: []string{"b"}},
+		{Name: "b", DependsOn: []string{"c"}},
+		{Name: "c", DependsOn: []string{"a"}},
+	}
+
+	_, err := sortProcesses(procs)
+	assert.True(t, errors.Is(err, ErrDependencyCycle))
+	assert.Contains(t, err.Error(), "a -> b -> c -> a")
+}
+
+func TestManager_StopOrder(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	go manager.Run()
+
+	db, err := manager.Start(StartReq{Name: "db", Binary: "sleep", Args: []string{"10"}, Dir: "deps"})
+	assert.NoError(t, err)
+	api, err := manager.Start(StartReq{Name: "api", Binary: "sleep", Args: []string{"10"}, Dir: "deps", DependsOn: []string{db.Name}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, manager.Stop())
+	assert.Equal(t, StateStopped, db.State())
+	assert.Equal(t, StateStopped, api.State())
+	assert.False(t, db.Stopped)
+
+	dbRuns, apiRuns := db.History(), api.History()
+	assert.False(t, apiRuns[len(apiRuns)-1].EndAt.After(dbRuns[len(dbRuns)-1].EndAt))
+}
+
+func TestManager_StoppedDependency(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	db, err := manager.Start(StartReq{Name: "db", Binary: "sleep", Args: []string{"10"}, Dir: "deps"})
+	assert.NoError(t, err)
+	_, err = manager.StopProcessWait(db.Name)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = manager.Start(StartReq{Name: "api", Binary: "sleep", Args: []string{"10"}, Dir: "deps", DependsOn: []string{db.Name}})
+	assert.True(t, errors.Is(err, ErrDependencyStopped))
+	assert.True(t, time.Since(start) < DefaultDependencyTimeout)
+	_, ok := manager.getProcess("api")
+	assert.False(t, ok)
+}
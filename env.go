@@ -0,0 +1,371 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// EnvInherit starts from the env of the manager, the default unless Env
+	// is set without EnvMode and EnvFile, then Env is the whole env as is
+	EnvInherit = "inherit"
+	// EnvClean starts from an empty env
+	EnvClean = "clean"
+	// EnvAllowlist starts from the vars of the manager matching EnvAllow
+	EnvAllowlist = "allowlist"
+)
+
+// RedactedValue replaces the values of secret vars in a description
+const RedactedValue = "<redacted>"
+
+// minSecretLen the least length of a secret value redacted inside other vars,
+// a shorter one like "1" would redact most of the env
+const minSecretLen = 6
+
+// SecretEnvPatterns the names of the vars whose values are redacted, matched
+// upper cased with path.Match
+var SecretEnvPatterns = []string{
+	"*SECRET*", "*PASSWORD*", "*PASSWD*", "*TOKEN*", "*CREDENTIAL*",
+	"*PRIVATE*", "*_KEY", "*_KEY_*", "*APIKEY*", "*DSN*",
+}
+
+// environ an env being built, later vars replace earlier ones in place
+type environ struct {
+	vars  []string
+	index map[string]int
+}
+
+// newEnviron an empty env, not nil, a nil env of a command is the manager env
+func newEnviron() *environ {
+	return &environ{vars: []string{}, index: make(map[string]int)}
+}
+
+func (e *environ) set(key, value string) {
+	if i, ok := e.index[key]; ok {
+		e.vars[i] = key + "=" + value
+		return
+	}
+	e.index[key] = len(e.vars)
+	e.vars = append(e.vars, key+"="+value)
+}
+
+// lookup key in e, then in the env of the manager
+func (e *environ) lookup(key string) (string, bool) {
+	if i, ok := e.index[key]; ok {
+		return e.vars[i][len(key)+1:], true
+	}
+	return os.LookupEnv(key)
+}
+
+// replacesEnv reports whether the Env of req is the whole env, taken as is,
+// the way an Env was used before EnvMode and EnvFile
+func (req StartReq) replacesEnv() bool {
+	return req.Env != nil && len(req.EnvMode) == 0 && len(req.EnvFile) == 0
+}
+
+// buildEnv the env of a process in dir defined by req: the base env of its
+// EnvMode, then its EnvFile files in order, then its Env, values are
+// interpolated against the vars defined before and the manager env
+func buildEnv(req StartReq, dir string) ([]string, error) {
+	if req.replacesEnv() {
+		return append([]string{}, req.Env...), nil
+	}
+
+	var env = newEnviron()
+
+	switch req.EnvMode {
+	case "", EnvInherit:
+		for _, kv := range os.Environ() {
+			if key, value, ok := splitEnv(kv); ok {
+				env.set(key, value)
+			}
+		}
+	case EnvClean:
+	case EnvAllowlist:
+		for _, kv := range os.Environ() {
+			if key, value, ok := splitEnv(kv); ok && matchEnv(req.EnvAllow, key) {
+				env.set(key, value)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown env mode %s", req.EnvMode)
+	}
+
+	for _, file := range req.EnvFile {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if err := env.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, kv := range req.Env {
+		key, value, ok := splitEnv(kv)
+		if !ok {
+			return nil, fmt.Errorf("invalid env %s", kv)
+		}
+		env.set(key, expandEnv(value, env.lookup, false))
+	}
+
+	return env.vars, nil
+}
+
+func splitEnv(kv string) (key, value string, ok bool) {
+	i := strings.IndexByte(kv, '=')
+	if i <= 0 {
+		return "", "", false
+	}
+	return kv[:i], kv[i+1:], true
+}
+
+func matchEnv(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadFile sets the vars of a .env file: KEY=VALUE lines, optionally
+// prefixed with export, # comments, and values in single quotes taken as is
+// or in double quotes with \n, \t, \", \\ and \$ escapes, which may span lines
+func (e *environ) loadFile(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var (
+		data   = string(b)
+		lineNo = 1
+	)
+	for len(data) > 0 {
+		var line, rest = data, ""
+		if i := strings.IndexByte(data, '\n'); i >= 0 {
+			line, rest = data[:i], data[i+1:]
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || trimmed[0] == '#' {
+			data = rest
+			lineNo++
+			continue
+		}
+		trimmed = strings.TrimPrefix(trimmed, "export ")
+
+		eq := strings.IndexByte(trimmed, '=')
+		if eq < 0 {
+			return fmt.Errorf("%s:%d: missing =", filename, lineNo)
+		}
+		key := strings.TrimSpace(trimmed[:eq])
+		if !validEnvKey(key) {
+			return fmt.Errorf("%s:%d: invalid name %q", filename, lineNo, key)
+		}
+
+		value := strings.TrimLeft(trimmed[eq+1:], " \t")
+		if len(value) == 0 || (value[0] != '"' && value[0] != '\'') {
+			// an unquoted value ends at a comment
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			e.set(key, expandEnv(strings.TrimSpace(value), e.lookup, false))
+			data = rest
+			lineNo++
+			continue
+		}
+
+		// a quoted value, from the quote in data up to the closing one
+		var (
+			quote = value[0]
+			start = strings.IndexByte(data, quote) + 1
+			end   = closingQuote(data, start, quote)
+		)
+		if end < 0 {
+			return fmt.Errorf("%s:%d: unterminated quote", filename, lineNo)
+		}
+		raw := data[start:end]
+		if quote == '\'' {
+			e.set(key, raw)
+		} else {
+			e.set(key, expandEnv(raw, e.lookup, true))
+		}
+
+		lineNo += strings.Count(raw, "\n")
+		data = data[end+1:]
+		if i := strings.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			line, data = data, ""
+		}
+		if after := strings.TrimSpace(line); len(after) > 0 && after[0] != '#' {
+			return fmt.Errorf("%s:%d: unexpected %q after the value", filename, lineNo, after)
+		}
+		lineNo++
+	}
+	return nil
+}
+
+// closingQuote the index of the quote closing a value starting at start,
+// backslash escaped quotes are skipped in double quotes, -1 when missing
+func closingQuote(data string, start int, quote byte) int {
+	for i := start; i < len(data); i++ {
+		switch {
+		case data[i] == '\\' && quote == '"':
+			i++
+		case data[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func validEnvKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for i, c := range key {
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// expandEnv replaces ${NAME} and ${NAME:-default} in s with the value of
+// NAME, empty when it is not defined, escapes handles backslash escapes
+func expandEnv(s string, lookup func(string) (string, bool), escapes bool) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escapes && s[i] == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case '"', '\\', '$':
+				out.WriteByte(s[i])
+			default:
+				out.WriteByte('\\')
+				out.WriteByte(s[i])
+			}
+		case strings.HasPrefix(s[i:], "${"):
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				out.WriteString(s[i:])
+				return out.String()
+			}
+
+			var (
+				name         = s[i+2 : i+end]
+				defaultValue string
+			)
+			if j := strings.Index(name, ":-"); j >= 0 {
+				name, defaultValue = name[:j], name[j+2:]
+			}
+			if value, ok := lookup(name); ok && len(value) > 0 {
+				out.WriteString(value)
+			} else {
+				out.WriteString(defaultValue)
+			}
+			i += end
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+	return out.String()
+}
+
+// isSecretEnv reports whether the value of key is redacted
+func isSecretEnv(key string) bool {
+	return matchEnv(SecretEnvPatterns, strings.ToUpper(key))
+}
+
+// redactEnv env with the values of the secret vars redacted, and the values
+// of other vars containing a secret value of minSecretLen or more, like a
+// password in a url
+func redactEnv(env []string) []string {
+	var secrets = make([]string, 0)
+	for _, kv := range env {
+		if key, value, ok := splitEnv(kv); ok && isSecretEnv(key) && len(value) >= minSecretLen {
+			secrets = append(secrets, value)
+		}
+	}
+
+	var out = make([]string, 0, len(env))
+	for _, kv := range env {
+		if key, value, ok := splitEnv(kv); ok && (isSecretEnv(key) || containsAny(value, secrets)) {
+			kv = key + "=" + RedactedValue
+		}
+		out = append(out, kv)
+	}
+	return out
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessDescription a process with the definition of its env
+type ProcessDescription struct {
+	Status   ProcessStatus
+	EnvMode  string
+	EnvAllow []string
+	EnvFile  []string
+	// Env the env the process is started with, secret values redacted
+	Env []string
+}
+
+// Describe the status of a process and its effective env
+func (m *Manager) Describe(name string) (*ProcessDescription, error) {
+	proc, ok := m.getProcess(name)
+	if !ok {
+		return nil, ErrProcessNotFound
+	}
+
+	var desc = ProcessDescription{
+		Status:   proc.Snapshot(),
+		EnvMode:  proc.EnvMode,
+		EnvAllow: proc.EnvAllow,
+		EnvFile:  proc.EnvFile,
+	}
+	switch {
+	case len(desc.EnvMode) > 0:
+	case proc.request().replacesEnv():
+		desc.EnvMode = EnvClean
+	default:
+		desc.EnvMode = EnvInherit
+	}
+
+	if cmd := proc.command(); cmd != nil {
+		desc.Env = redactEnv(cmd.Env)
+	}
+	return &desc, nil
+}
+
+// running reports whether the run closing done has not exited yet
+func running(done <-chan struct{}) bool {
+	if done == nil {
+		return false
+	}
+	select {
+	case <-done:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,157 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+const testEnvFile = `# database
+export DB_HOST=localhost
+DB_PORT = 5432 # default port
+DB_URL="postgres://${DB_HOST}:${DB_PORT}/${DB_NAME:-app}"
+DB_PASSWORD='p@ss ${not} expanded'
+HOME_DIR=${TEST_ENV_HOME}/app
+CERT="line one
+line \"two\"\tend"
+ESCAPED="\${DB_HOST}"
+`
+
+func TestEnviron_LoadFile(t *testing.T) {
+	os.Setenv("TEST_ENV_HOME", "/home/test")
+	defer os.Unsetenv("TEST_ENV_HOME")
+
+	assert.NoError(t, os.MkdirAll("./tmp/env", 0755))
+	assert.NoError(t, os.WriteFile("./tmp/env/.env", []byte(testEnvFile), 0644))
+
+	env := newEnviron()
+	assert.NoError(t, env.loadFile("./tmp/env/.env"))
+	assert.Equal(t, []string{
+		"DB_HOST=localhost",
+		"DB_PORT=5432",
+		"DB_URL=postgres://localhost:5432/app",
+		"DB_PASSWORD=p@ss ${not} expanded",
+		"HOME_DIR=/home/test/app",
+		"CERT=line one\nline \"two\"\tend",
+		"ESCAPED=${DB_HOST}",
+	}, env.vars)
+
+	assert.NoError(t, os.WriteFile("./tmp/env/bad.env", []byte("A=1\nB=\"open\n"), 0644))
+	assert.Error(t, newEnviron().loadFile("./tmp/env/bad.env"))
+	assert.NoError(t, os.WriteFile("./tmp/env/bad.env", []byte("A=1\n1B=2\n"), 0644))
+	assert.Error(t, newEnviron().loadFile("./tmp/env/bad.env"))
+}
+
+func TestBuildEnv(t *testing.T) {
+	os.Setenv("TEST_ENV_KEEP", "kept")
+	defer os.Unsetenv("TEST_ENV_KEEP")
+
+	assert.NoError(t, os.MkdirAll("./tmp/env", 0755))
+	assert.NoError(t, os.WriteFile("./tmp/env/base.env", []byte("A=1\nB=${A}2\n"), 0644))
+
+	env, err := buildEnv(StartReq{
+		EnvMode: EnvClean,
+		EnvFile: []string{"base.env"},
+		Env:     []string{"B=${B}3", "C=${TEST_ENV_KEEP}"},
+	}, "./tmp/env")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A=1", "B=123", "C=kept"}, env)
+
+	env, err = buildEnv(StartReq{EnvMode: EnvAllowlist, EnvAllow: []string{"TEST_ENV_*"}}, "./tmp/env")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"TEST_ENV_KEEP=kept"}, env)
+
+	env, err = buildEnv(StartReq{}, "./tmp/env")
+	assert.NoError(t, err)
+	assert.Contains(t, env, "TEST_ENV_KEEP=kept")
+
+	env, err = buildEnv(StartReq{EnvMode: EnvClean}, "./tmp/env")
+	assert.NoError(t, err)
+	assert.NotNil(t, env)
+	assert.Empty(t, env)
+
+	// an Env without EnvMode and EnvFile is the whole env, as is
+	env, err = buildEnv(StartReq{Env: []string{"A=${TEST_ENV_KEEP}"}}, "./tmp/env")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"A=${TEST_ENV_KEEP}"}, env)
+
+	_, err = buildEnv(StartReq{EnvMode: "none"}, "./tmp/env")
+	assert.Error(t, err)
+	_, err = buildEnv(StartReq{EnvFile: []string{"missing.env"}}, "./tmp/env")
+	assert.Error(t, err)
+}
+
+func TestRedactEnv(t *testing.T) {
+	assert.Equal(t, []string{
+		"PATH=/bin",
+		"DB_PASSWORD=" + RedactedValue,
+		"api_token=" + RedactedValue,
+		"AWS_SECRET_ACCESS_KEY=" + RedactedValue,
+		"KEYBOARD=us",
+	}, redactEnv([]string{"PATH=/bin", "DB_PASSWORD=x", "api_token=y", "AWS_SECRET_ACCESS_KEY=z", "KEYBOARD=us"}))
+
+	// a secret interpolated into another var
+	assert.Equal(t, []string{
+		"DB_PASSWORD=" + RedactedValue,
+		"DB_URL=" + RedactedValue,
+		"DB_HOST=h",
+	}, redactEnv([]string{"DB_PASSWORD=s3cret", "DB_URL=postgres://u:s3cret@h", "DB_HOST=h"}))
+
+	// too short to be looked for in other vars
+	assert.Equal(t, []string{
+		"USE_TOKEN=" + RedactedValue,
+		"WORKERS=1",
+	}, redactEnv([]string{"USE_TOKEN=1", "WORKERS=1"}))
+}
+
+func TestManager_Describe(t *testing.T) {
+	dir := filepath.Join("./tmp", "describe")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("NAME=world\nAPI_TOKEN=abc\n"), 0644))
+
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "echo hello $NAME $API_TOKEN"},
+		Dir:     "describe",
+		Restart: RestartPolicy{Mode: RestartNever},
+		EnvMode: EnvClean,
+		EnvFile: []string{".env"},
+		Env:     []string{"GREETING=hello ${NAME}"},
+	})
+	assert.NoError(t, err)
+
+	select {
+	case <-proc.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("process not exited")
+	}
+
+	lines, _, err := manager.Logs("sh", 0, 1, 0)
+	assert.NoError(t, err)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "hello world abc", lines[0].Line)
+
+	desc, err := manager.Describe("sh")
+	assert.NoError(t, err)
+	assert.Equal(t, "sh", desc.Status.Name)
+	assert.Equal(t, EnvClean, desc.EnvMode)
+	assert.Equal(t, []string{"NAME=world", "API_TOKEN=" + RedactedValue, "GREETING=hello world"}, desc.Env)
+
+	// the env files are read when the process is defined, not on describe
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("NAME=again\n"), 0644))
+	desc, err = manager.Describe("sh")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"NAME=world", "API_TOKEN=" + RedactedValue, "GREETING=hello world"}, desc.Env)
+
+	_, err = manager.Describe("nope")
+	assert.Equal(t, ErrProcessNotFound, err)
+}
@@ -3,5 +3,8 @@ package process
 import "errors"
 
 var (
-	ErrProcessNotFound = errors.New(`process not found`)
+	ErrProcessNotFound   = errors.New(`process not found`)
+	ErrDependencyCycle   = errors.New(`dependency cycle`)
+	ErrProcessExists     = errors.New(`process exists`)
+	ErrDependencyStopped = errors.New(`dependency stopped`)
 )
@@ -0,0 +1,94 @@
+package process
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultEventBuffer events kept in memory by the manager
+var DefaultEventBuffer = 1000
+
+// Event something noticed about a process, like an output line matching a trigger
+type Event struct {
+	Seq     uint64
+	Time    time.Time
+	Process string
+	// Trigger the name of the trigger which fired
+	Trigger string
+	Action  string
+	Stream  string
+	Line    string
+}
+
+// eventBuffer keeps the recent events of all processes in order
+type eventBuffer struct {
+	mu     sync.Mutex
+	events []Event
+	next   uint64
+	notify chan struct{}
+}
+
+func newEventBuffer() *eventBuffer {
+	return &eventBuffer{next: 1, notify: make(chan struct{})}
+}
+
+func (b *eventBuffer) append(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event.Seq = b.next
+	b.next++
+	b.events = append(b.events, event)
+	if over := len(b.events) - DefaultEventBuffer; over > 0 {
+		b.events = append(b.events[:0:0], b.events[over:]...)
+	}
+
+	// wake up followers
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// since the events from seq on, waiting up to wait for one when there is none yet
+func (b *eventBuffer) since(seq uint64, wait time.Duration) ([]Event, uint64) {
+	b.mu.Lock()
+	if seq >= b.next && wait > 0 {
+		notify := b.notify
+		b.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-time.After(wait):
+		}
+		b.mu.Lock()
+	}
+	defer b.mu.Unlock()
+
+	var events = make([]Event, 0)
+	for _, event := range b.events {
+		if event.Seq >= seq {
+			events = append(events, event)
+		}
+	}
+	return events, b.next
+}
+
+// emit records an event
+func (m *Manager) emit(event Event) {
+	if m.events == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	m.events.append(event)
+}
+
+// Events the events kept in memory from seq since on, waiting up to wait for
+// one when there is none yet, next is the seq to pass as since for the
+// following call
+func (m *Manager) Events(since uint64, wait time.Duration) ([]Event, uint64) {
+	if m.events == nil {
+		return []Event{}, since
+	}
+	return m.events.since(since, wait)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/hysios/log"
 	"github.com/hysios/process"
@@ -15,11 +16,23 @@ import (
 )
 
 var (
-	climode bool
-	run     bool
-	status  bool
-	stop    bool
-	remove  bool
+	climode  bool
+	run      bool
+	status   bool
+	stop     bool
+	remove   bool
+	logs     bool
+	events   bool
+	metrics  bool
+	describe bool
+	follow   bool
+	lines    int
+	search   string
+	stream   string
+	since    string
+	until    string
+	offset   int
+	limit    int
 )
 
 func init() {
@@ -28,9 +41,22 @@ func init() {
 	flag.BoolVar(&status, "status", false, "List All Processes Status")
 	flag.BoolVar(&stop, "stop", false, "Stop Process running")
 	flag.BoolVar(&remove, "remove", false, "Remove Process")
+	flag.BoolVar(&logs, "logs", false, "Show Process output")
+	flag.BoolVar(&events, "events", false, "Show the events of the processes")
+	flag.BoolVar(&metrics, "metrics", false, "Show the resource usage of a process")
+	flag.BoolVar(&describe, "describe", false, "Show the status and env of a process")
+	flag.BoolVar(&follow, "f", false, "Follow output in logs and events mode")
+	flag.IntVar(&lines, "lines", 20, "Last lines to show in logs mode")
+	flag.StringVar(&search, "search", "", "Search the log files of a process for a regexp")
+	flag.StringVar(&stream, "stream", "", "Stream to search, stdout or stderr")
+	flag.StringVar(&since, "since", "", "Search lines since a RFC3339 time or a duration ago like 1h")
+	flag.StringVar(&until, "until", "", "Search lines until a RFC3339 time or a duration ago like 1h")
+	flag.IntVar(&offset, "offset", 0, "Offset of the search page")
+	flag.IntVar(&limit, "limit", 100, "Lines of the search page")
 }
 
 func main() {
+	process.SandboxMain()
 	flag.Parse()
 	if climode {
 		cli, err := client.Open(&client.ClientOption{
@@ -98,6 +124,84 @@ func main() {
 				log.Fatalf("all status %s", err)
 			}
 			printTable(status)
+		case logs:
+			if len(flag.Args()) == 0 {
+				log.Fatalf("you must input process name")
+			}
+
+			ch, err := cli.Logs(flag.Args()[0], follow, lines)
+			if err != nil {
+				log.Fatalf("logs error %s", err)
+			}
+			for line := range ch {
+				fmt.Printf("[%s] %s\n", line.Stream, line.Line)
+			}
+		case events:
+			ch, err := cli.Events(follow)
+			if err != nil {
+				log.Fatalf("events error %s", err)
+			}
+			for event := range ch {
+				fmt.Printf("%s %s %s %s: %s\n", event.Time.Format("2006-01-02 15:04:05"), event.Process, event.Trigger, event.Action, event.Line)
+			}
+		case metrics:
+			if len(flag.Args()) == 0 {
+				log.Fatalf("you must input process name")
+			}
+
+			from, err := parseTime(since)
+			if err != nil {
+				log.Fatalf("invalid since %s", err)
+			}
+
+			samples, err := cli.Metrics(flag.Args()[0], from)
+			if err != nil {
+				log.Fatalf("metrics error %s", err)
+			}
+			printMetrics(samples)
+		case describe:
+			if len(flag.Args()) == 0 {
+				log.Fatalf("you must input process name")
+			}
+
+			desc, err := cli.Describe(flag.Args()[0])
+			if err != nil {
+				log.Fatalf("describe error %s", err)
+			}
+			printTable([]process.ProcessStatus{desc.Status})
+			fmt.Printf("env mode %s, env files %v\n", desc.EnvMode, desc.EnvFile)
+			for _, kv := range desc.Env {
+				fmt.Println(kv)
+			}
+		case len(search) > 0:
+			if len(flag.Args()) == 0 {
+				log.Fatalf("you must input process name")
+			}
+
+			var req = process.SearchLogsReq{
+				Name:    flag.Args()[0],
+				Stream:  stream,
+				Pattern: search,
+				Offset:  offset,
+				Limit:   limit,
+			}
+			if req.Since, err = parseTime(since); err != nil {
+				log.Fatalf("invalid since %s", err)
+			}
+			if req.Until, err = parseTime(until); err != nil {
+				log.Fatalf("invalid until %s", err)
+			}
+
+			found, next, err := cli.SearchLogs(req)
+			if err != nil {
+				log.Fatalf("search error %s", err)
+			}
+			for _, line := range found {
+				fmt.Printf("%s [%s] %s\n", line.Time.Format("2006-01-02 15:04:05"), line.Stream, line.Line)
+			}
+			if next > 0 {
+				fmt.Printf("more lines with -offset %d\n", next)
+			}
 		case status:
 			{
 
@@ -118,36 +222,56 @@ func main() {
 	}
 }
 
-func printTable(status map[string]interface{}) {
-	var (
-		data    = make([][]string, 0)
-		columns = []string{"Name"}
-		first   bool
-	)
+// parseTime parses a RFC3339 time, or a duration before now
+func parseTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
 
-	for name, val := range status {
-		var row = []string{name}
+func printMetrics(samples []process.MetricsSample) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Time", "Procs", "CPU%", "CPUSeconds", "RSS", "FDs", "Threads"})
 
-		if first {
-			vv, ok := val.(map[string]interface{})
-			if !ok {
-				continue
-			}
+	for _, sample := range samples {
+		table.Append([]string{
+			sample.Time.Format("2006-01-02 15:04:05"),
+			fmt.Sprintf("%d", sample.Processes),
+			fmt.Sprintf("%.1f", sample.CPUPercent),
+			fmt.Sprintf("%.2f", sample.CPUSeconds),
+			fmt.Sprintf("%d", sample.RSS),
+			fmt.Sprintf("%d", sample.FDs),
+			fmt.Sprintf("%d", sample.Threads),
+		})
+	}
+	table.Render()
+}
 
-			for _, col := range columns[1:] {
-				row = append(row, fmt.Sprintf("%v", vv[col]))
-			}
-		} else {
-			for col, v := range val.(map[string]interface{}) {
+func printTable(status []process.ProcessStatus) {
+	var (
+		data    = make([][]string, 0)
+		columns = []string{"Name", "Pid", "State", "Health", "Restarts", "StartAt", "LastExit"}
+	)
 
-				row = append(row, fmt.Sprintf("%v", v))
-				if !first {
-					columns = append(columns, col)
-				}
-			}
-			first = true
+	for _, st := range status {
+		var lastExit string
+		if st.LastExit != nil {
+			lastExit = st.LastExit.String()
 		}
-		data = append(data, row)
+
+		data = append(data, []string{
+			st.Name,
+			fmt.Sprintf("%d", st.Pid),
+			string(st.State),
+			string(st.Health),
+			fmt.Sprintf("%d", st.Restarts),
+			st.StartAt.Format("2006-01-02 15:04:05"),
+			lastExit,
+		})
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
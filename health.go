@@ -0,0 +1,211 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+
+	"github.com/hysios/log"
+	"github.com/hysios/utils/convert"
+)
+
+// Health result of the health check of a process
+type Health string
+
+const (
+	HealthNone      Health = ""
+	HealthStarting  Health = "starting"
+	HealthHealthy   Health = "healthy"
+	HealthUnhealthy Health = "unhealthy"
+)
+
+const (
+	HealthCheckExec = "exec"
+	HealthCheckTCP  = "tcp"
+	HealthCheckHTTP = "http"
+)
+
+// HealthCheck probes a running process by running Command, dialing Address
+// or requesting URL, Type is guessed from the field set when empty
+type HealthCheck struct {
+	Type    string
+	Command []string
+	Address string
+	URL     string
+
+	Interval time.Duration
+	Timeout  time.Duration
+	// FailureThreshold consecutive failures mark the process unhealthy
+	FailureThreshold int
+	// StartPeriod failures right after start are not counted
+	StartPeriod time.Duration
+}
+
+var DefaultHealthCheck = HealthCheck{
+	Interval:         10 * time.Second,
+	Timeout:          5 * time.Second,
+	FailureThreshold: 3,
+}
+
+// Kind the check type, empty when no check is configured
+func (check HealthCheck) Kind() string {
+	switch {
+	case len(check.Type) > 0:
+		return check.Type
+	case len(check.Command) > 0:
+		return HealthCheckExec
+	case len(check.Address) > 0:
+		return HealthCheckTCP
+	case len(check.URL) > 0:
+		return HealthCheckHTTP
+	default:
+		return ""
+	}
+}
+
+func (check HealthCheck) validate() error {
+	switch check.Kind() {
+	case "":
+		return nil
+	case HealthCheckExec:
+		if len(check.Command) == 0 {
+			return fmt.Errorf("health check command is empty")
+		}
+	case HealthCheckTCP:
+		if len(check.Address) == 0 {
+			return fmt.Errorf("health check address is empty")
+		}
+	case HealthCheckHTTP:
+		if len(check.URL) == 0 {
+			return fmt.Errorf("health check url is empty")
+		}
+	default:
+		return fmt.Errorf("unknown health check type %s", check.Type)
+	}
+	return nil
+}
+
+// WithDefaults fills the zero fields of check from DefaultHealthCheck
+func (check HealthCheck) WithDefaults() HealthCheck {
+	if check.Interval <= 0 {
+		check.Interval = DefaultHealthCheck.Interval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultHealthCheck.Timeout
+	}
+	if check.FailureThreshold <= 0 {
+		check.FailureThreshold = DefaultHealthCheck.FailureThreshold
+	}
+	return check
+}
+
+// Check probes once, dir and env are used by exec checks
+func (check HealthCheck) Check(dir string, env []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+	defer cancel()
+
+	switch check.Kind() {
+	case HealthCheckExec:
+		if len(check.Command) == 0 {
+			return fmt.Errorf("health check command is empty")
+		}
+		cmd := exec.CommandContext(ctx, check.Command[0], check.Command[1:]...)
+		cmd.Dir = dir
+		cmd.Env = env
+		return cmd.Run()
+	case HealthCheckTCP:
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", check.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case HealthCheckHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("health check %s status %s", check.URL, resp.Status)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown health check type %s", check.Type)
+	}
+}
+
+// watchHealth probes the run of proc by cmd until done is closed, an
+// unhealthy process is stopped and its exit goes through the restart policy
+func (m *Manager) watchHealth(proc *Process, cmd *exec.Cmd, done <-chan struct{}) {
+	var (
+		check    = proc.HealthCheck.WithDefaults()
+		started  = time.Now()
+		failures int
+		ticker   = time.NewTicker(check.Interval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		err := check.Check(cmd.Dir, cmd.Env)
+		if err == nil {
+			failures = 0
+			proc.health.Store(string(HealthHealthy))
+			proc.compareAndSetState(StateStarting, StateRunning)
+			continue
+		}
+
+		if time.Since(started) < check.StartPeriod {
+			continue
+		}
+
+		failures++
+		log.Infof("process %s health check failed %d/%d: %s", proc.Name, failures, check.FailureThreshold, err)
+		if failures < check.FailureThreshold {
+			continue
+		}
+
+		proc.health.Store(string(HealthUnhealthy))
+		if proc.daemon.Load() > 0 {
+			log.Errorf("process %s is unhealthy, stop it", proc.Name)
+			proc.setExitReason(ExitReasonUnhealthy)
+			if err := m.terminate(proc, cmd, done); err != nil {
+				log.Errorf("stop process %s error %s", proc.Name, err)
+			}
+		}
+		return
+	}
+}
+
+func loadHealthCheck(v interface{}) HealthCheck {
+	var check HealthCheck
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return check
+	}
+
+	check.Type, _ = pm["Type"].(string)
+	check.Command, _ = convert.SliceString(pm["Command"])
+	check.Address, _ = pm["Address"].(string)
+	check.URL, _ = pm["URL"].(string)
+	check.Interval = toDuration(pm["Interval"])
+	check.Timeout = toDuration(pm["Timeout"])
+	check.FailureThreshold, _ = convert.Int(pm["FailureThreshold"])
+	check.StartPeriod = toDuration(pm["StartPeriod"])
+
+	return check
+}
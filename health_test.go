@@ -0,0 +1,111 @@
+package process
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestHealthCheck_Check(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+
+	check := HealthCheck{Address: l.Addr().String()}.WithDefaults()
+	assert.Equal(t, HealthCheckTCP, check.Kind())
+	assert.NoError(t, check.Check("", nil))
+
+	check = HealthCheck{Command: []string{"false"}}.WithDefaults()
+	assert.Equal(t, HealthCheckExec, check.Kind())
+	assert.Error(t, check.Check("", nil))
+}
+
+func TestManager_Unhealthy(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name: "sleep",
+		Args: []string{"10"},
+		Dir:  "sleep",
+		Restart: RestartPolicy{
+			Mode: RestartNever,
+		},
+		HealthCheck: HealthCheck{
+			Command:          []string{"false"},
+			Interval:         20 * time.Millisecond,
+			FailureThreshold: 2,
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, HealthStarting, proc.Health())
+
+	select {
+	case <-proc.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("unhealthy process not stopped")
+	}
+	assert.Equal(t, HealthUnhealthy, proc.Health())
+	assert.False(t, proc.Ready())
+}
+
+func TestManager_UnhealthyRestart(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name: "sleep",
+		Args: []string{"10"},
+		Dir:  "sleep",
+		Restart: RestartPolicy{
+			Mode:  RestartOnFailure,
+			Delay: 10 * time.Millisecond,
+		},
+		HealthCheck: HealthCheck{
+			Command:          []string{"false"},
+			Interval:         20 * time.Millisecond,
+			FailureThreshold: 2,
+		},
+	})
+	assert.NoError(t, err)
+	pid := proc.Snapshot().Pid
+
+	deadline := time.Now().Add(3 * time.Second)
+	for proc.Snapshot().Restarts == 0 || proc.Snapshot().Pid == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("unhealthy process not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NotEqual(t, pid, proc.Snapshot().Pid)
+	assert.Equal(t, ExitReasonUnhealthy, proc.LastExit().Reason)
+}
+
+func TestManager_UnknownHealthCheck(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	os.RemoveAll("./tmp/badcheck")
+	_, err := manager.Start(StartReq{
+		Name:        "badcheck",
+		Args:        []string{"10"},
+		Dir:         "badcheck",
+		HealthCheck: HealthCheck{Type: "grpc", Address: "127.0.0.1:1"},
+	})
+	assert.Error(t, err)
+	// the dir of an invalid process is not created
+	_, err = os.Stat("./tmp/badcheck")
+	assert.True(t, os.IsNotExist(err))
+}
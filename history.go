@@ -0,0 +1,201 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hysios/log"
+)
+
+var (
+	// DefaultHistorySize runs kept per process
+	DefaultHistorySize = 20
+	// DefaultStderrTail lines of stderr kept for a crashed run
+	DefaultStderrTail = 20
+)
+
+const (
+	ExitReasonExited    = "exited"
+	ExitReasonSignaled  = "signaled"
+	ExitReasonStopped   = "stopped"
+	ExitReasonRestarted = "restarted"
+	ExitReasonUnhealthy = "unhealthy"
+	ExitReasonTriggered = "triggered"
+	ExitReasonMemory    = "memory-limit"
+	ExitReasonCPU       = "cpu-limit"
+	ExitReasonOOM       = "oom-killed"
+	ExitReasonSeccomp   = "seccomp"
+)
+
+// RunRecord a finished run of a process
+type RunRecord struct {
+	Pid     int32
+	StartAt time.Time
+	EndAt   time.Time
+	Runtime time.Duration
+	Exit    ExitStatus
+	// Stderr the last lines written to stderr, only kept when the run crashed
+	Stderr []string
+	// Adopted the run was not started by the manager, its exit code is -1
+	// because there is no wait status, and its output was not captured
+	Adopted bool
+}
+
+// runHistory a bounded list of runs, persisted as json next to the pid file
+type runHistory struct {
+	mu       sync.Mutex
+	filename string
+	records  []RunRecord
+}
+
+func (h *runHistory) add(record RunRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records = append(h.records, record)
+	if over := len(h.records) - DefaultHistorySize; over > 0 {
+		h.records = append(h.records[:0:0], h.records[over:]...)
+	}
+}
+
+func (h *runHistory) list() []RunRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]RunRecord(nil), h.records...)
+}
+
+func (h *runHistory) save() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.filename) == 0 {
+		return nil
+	}
+
+	b, err := json.Marshal(h.records)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.filename, b, 0644)
+}
+
+func (h *runHistory) load(filename string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.filename) > 0 {
+		// loaded already
+		return nil
+	}
+	h.filename = filename
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return json.Unmarshal(b, &h.records)
+}
+
+// tailWriter keeps the last lines written to it
+type tailWriter struct {
+	mu    sync.Mutex
+	size  int
+	lines []string
+	part  []byte
+}
+
+func newTailWriter(size int) *tailWriter {
+	return &tailWriter{size: size}
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var data = append(w.part, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		w.lines = append(w.lines, string(data[:i]))
+		data = data[i+1:]
+	}
+	w.part = append(w.part[:0:0], data...)
+
+	if over := len(w.lines) - w.size; over > 0 {
+		w.lines = append(w.lines[:0:0], w.lines[over:]...)
+	}
+	return len(p), nil
+}
+
+// Lines the kept lines, with the unfinished last line if any
+func (w *tailWriter) Lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var lines = append([]string(nil), w.lines...)
+	if len(w.part) > 0 {
+		lines = append(lines, string(w.part))
+	}
+	if over := len(lines) - w.size; over > 0 {
+		lines = lines[over:]
+	}
+	return lines
+}
+
+// setExitReason records why the current run is being ended, the reason goes
+// into the exit status of the run
+func (p *Process) setExitReason(reason string) {
+	p.exitReason.Store(reason)
+}
+
+// recordRun adds a finished run to the history and persists it
+func (p *Process) recordRun(record RunRecord) {
+	if p.history == nil {
+		return
+	}
+
+	p.history.add(record)
+	if err := p.history.save(); err != nil {
+		log.Errorf("save history of %s error %s", p.Name, err)
+	}
+}
+
+// History the finished runs of the process, oldest first
+func (p *Process) History() []RunRecord {
+	if p.history == nil {
+		return nil
+	}
+	return p.history.list()
+}
+
+// History the finished runs of a process, oldest first
+func (m *Manager) History(name string) ([]RunRecord, error) {
+	proc, ok := m.getProcess(name)
+	if !ok {
+		return nil, ErrProcessNotFound
+	}
+
+	return proc.History(), nil
+}
+
+// loadHistory reads the history persisted by a previous manager, once
+func (p *Process) loadHistory() {
+	cmd := p.command()
+	if p.history == nil || cmd == nil {
+		return
+	}
+
+	if err := p.history.load(path.Join(cmd.Dir, p.fileName(".history"))); err != nil {
+		log.Errorf("load history of %s error %s", p.Name, err)
+	}
+}
@@ -0,0 +1,50 @@
+package process
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestTailWriter(t *testing.T) {
+	w := newTailWriter(2)
+	fmt.Fprint(w, "one\ntwo\nthr")
+	fmt.Fprint(w, "ee\nfour")
+
+	assert.Equal(t, []string{"three", "four"}, w.Lines())
+}
+
+func TestManager_History(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "echo boom >&2; exit 3"},
+		Dir:     "history",
+		Restart: RestartPolicy{Mode: RestartNever},
+	})
+	assert.NoError(t, err)
+	<-proc.done
+	time.Sleep(50 * time.Millisecond)
+
+	history, err := manager.History("sh")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, history)
+
+	record := history[len(history)-1]
+	assert.Equal(t, 3, record.Exit.Code)
+	assert.Equal(t, ExitReasonExited, record.Exit.Reason)
+	assert.Equal(t, []string{"boom"}, record.Stderr)
+
+	// a new manager reads the persisted history
+	reloaded := NewProcess("sh", proc.cmd, nil)
+	reloaded.loadHistory()
+	assert.Len(t, reloaded.History(), len(history))
+	assert.Equal(t, record.Stderr, reloaded.History()[len(history)-1].Stderr)
+}
@@ -0,0 +1,147 @@
+package process
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+const (
+	// LogFormatRaw the output is written as is
+	LogFormatRaw = "raw"
+	// LogFormatText each line is prefixed with its time, process and stream
+	LogFormatText = "text"
+	// LogFormatJSON each line is written as a json object, lines which are a
+	// json object already get the fields added instead of being wrapped
+	LogFormatJSON = "json"
+)
+
+// partialMark follows the stream of a partial line in the text format
+const partialMark = "(partial)"
+
+// logFormatter formats the output lines of a stream of a process
+type logFormatter struct {
+	timeFormat string
+	json       bool
+	process    string
+	group      string
+	instance   int
+	stream     string
+}
+
+func newLogFormatter(p *Process, stream string, cfg LogConfig) *logFormatter {
+	var f = &logFormatter{
+		timeFormat: cfg.TimeFormat,
+		json:       cfg.Format == LogFormatJSON,
+		process:    p.Name,
+		group:      p.Group,
+		instance:   p.Instance,
+		stream:     stream,
+	}
+	if len(f.timeFormat) == 0 {
+		f.timeFormat = time.RFC3339Nano
+	}
+	return f
+}
+
+// format a line, without its newline, read at t, a partial line is marked
+// so it is not taken for a complete one
+func (f *logFormatter) format(t time.Time, line string, partial bool) []byte {
+	if f.json {
+		return f.formatJSON(t, line, partial)
+	}
+
+	var stream = f.stream
+	if partial {
+		stream += partialMark
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(t.Format(f.timeFormat))
+	buf.WriteByte(' ')
+	if len(f.group) > 0 {
+		buf.WriteString(f.group + "[" + strconv.Itoa(f.instance) + "]")
+	} else {
+		buf.WriteString(f.process)
+	}
+	buf.WriteString(" " + stream + ": " + line + "\n")
+	return buf.Bytes()
+}
+
+func (f *logFormatter) formatJSON(t time.Time, line string, partial bool) []byte {
+	var fields = []struct {
+		key   string
+		value interface{}
+	}{
+		{"time", t.Format(f.timeFormat)},
+		{"process", f.process},
+		{"group", f.group},
+		{"instance", f.instance},
+		{"stream", f.stream},
+	}
+	if len(f.group) == 0 {
+		fields = append(fields[:2], fields[4:]...)
+	}
+	if partial {
+		fields = append(fields, struct {
+			key   string
+			value interface{}
+		}{"partial", true})
+	}
+
+	var (
+		buf      bytes.Buffer
+		obj      map[string]json.RawMessage
+		trimmed  = bytes.TrimSpace([]byte(line))
+		isObject = len(trimmed) > 0 && trimmed[0] == '{' && json.Unmarshal(trimmed, &obj) == nil
+	)
+
+	buf.WriteByte('{')
+	for _, field := range fields {
+		if _, ok := obj[field.key]; ok {
+			// the line has its own value
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		b, _ := json.Marshal(field.value)
+		buf.WriteString(strconv.Quote(field.key) + ":")
+		buf.Write(b)
+	}
+
+	if isObject {
+		// enrich the object, keeping its fields as they were written
+		rest := bytes.TrimSpace(trimmed[1:])
+		if len(rest) > 1 && buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(rest)
+	} else {
+		b, _ := json.Marshal(line)
+		buf.WriteString(`,"line":`)
+		buf.Write(b)
+		buf.WriteByte('}')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// format wraps w, a log file of the stream of p, to write the lines in the
+// format of cfg
+func (o *outputs) format(p *Process, stream string, w io.Writer, cfg LogConfig) io.Writer {
+	switch cfg.Format {
+	case LogFormatText, LogFormatJSON:
+	default:
+		return w
+	}
+
+	f := newLogFormatter(p, stream, cfg)
+	lw := newLineWriter(func(line string, partial bool) {
+		w.Write(f.format(time.Now(), line, partial))
+	})
+	o.lines = append(o.lines, lw)
+	return lw
+}
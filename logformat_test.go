@@ -0,0 +1,35 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestLogFormatter(t *testing.T) {
+	var (
+		at   = time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+		proc = &Process{Name: "web-1", Group: "web", Instance: 1}
+	)
+
+	text := newLogFormatter(proc, StreamStderr, LogConfig{Format: LogFormatText, TimeFormat: time.RFC3339})
+	assert.Equal(t, "2021-06-01T10:00:00Z web[1] stderr: boom\n", string(text.format(at, "boom", false)))
+
+	jsonf := newLogFormatter(&Process{Name: "api"}, StreamStdout, LogConfig{Format: LogFormatJSON, TimeFormat: time.RFC3339})
+	assert.Equal(t, `{"time":"2021-06-01T10:00:00Z","process":"api","stream":"stdout","line":"say \"hi\""}`+"\n",
+		string(jsonf.format(at, `say "hi"`, false)))
+
+	// json lines are enriched, their own fields win
+	assert.Equal(t, `{"process":"api","stream":"stdout","time":"now","level":"info"}`+"\n",
+		string(jsonf.format(at, `{"time":"now","level":"info"}`, false)))
+	assert.Equal(t, `{"time":"2021-06-01T10:00:00Z","process":"api","stream":"stdout"}`+"\n",
+		string(jsonf.format(at, `{}`, false)))
+	assert.Equal(t, `{"time":"2021-06-01T10:00:00Z","process":"api","stream":"stdout","line":"{not json"}`+"\n",
+		string(jsonf.format(at, `{not json`, false)))
+
+	// partial lines, flushed before their newline, are marked
+	assert.Equal(t, "2021-06-01T10:00:00Z web[1] stderr(partial): bo\n", string(text.format(at, "bo", true)))
+	assert.Equal(t, `{"time":"2021-06-01T10:00:00Z","process":"api","stream":"stdout","partial":true,"line":"say"}`+"\n",
+		string(jsonf.format(at, "say", true)))
+}
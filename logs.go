@@ -0,0 +1,158 @@
+package process
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// DefaultLogBuffer lines of output kept in memory per process for Logs
+var DefaultLogBuffer = 1000
+
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// LogLine a line of process output
+type LogLine struct {
+	Seq     uint64
+	Time    time.Time
+	Process string
+	Stream  string
+	Line    string
+	// Partial the line had no newline yet when it was flushed, its rest
+	// follows as the next line
+	Partial bool
+}
+
+// logBuffer keeps the recent output lines of a process, stdout and stderr
+// interleaved in the order they were written
+type logBuffer struct {
+	mu     sync.Mutex
+	lines  []LogLine
+	next   uint64
+	notify chan struct{}
+}
+
+func newLogBuffer() *logBuffer {
+	return &logBuffer{next: 1, notify: make(chan struct{})}
+}
+
+func (b *logBuffer) append(line LogLine) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	line.Seq = b.next
+	b.next++
+	b.lines = append(b.lines, line)
+	if over := len(b.lines) - DefaultLogBuffer; over > 0 {
+		b.lines = append(b.lines[:0:0], b.lines[over:]...)
+	}
+
+	// wake up followers
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// tail the last n lines and the seq of the next line
+func (b *logBuffer) tail(n int) ([]LogLine, uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines = b.lines
+	if n >= 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append([]LogLine(nil), lines...), b.next
+}
+
+// since the lines from seq on, waiting up to wait for one when there is none yet
+func (b *logBuffer) since(seq uint64, wait time.Duration) ([]LogLine, uint64) {
+	b.mu.Lock()
+	if seq >= b.next && wait > 0 {
+		notify := b.notify
+		b.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-time.After(wait):
+		}
+		b.mu.Lock()
+	}
+	defer b.mu.Unlock()
+
+	var lines = make([]LogLine, 0)
+	for _, line := range b.lines {
+		if line.Seq >= seq {
+			lines = append(lines, line)
+		}
+	}
+	return lines, b.next
+}
+
+// lineWriter splits the written bytes into lines for fn, a trailing partial
+// line is kept until its newline arrives or the writer is flushed
+type lineWriter struct {
+	mu   sync.Mutex
+	part []byte
+	fn   func(line string, partial bool)
+}
+
+func newLineWriter(fn func(line string, partial bool)) *lineWriter {
+	return &lineWriter{fn: fn}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var data = append(w.part, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		w.fn(string(bytes.TrimSuffix(data[:i], []byte("\r"))), false)
+		data = data[i+1:]
+	}
+	w.part = append(w.part[:0:0], data...)
+
+	return len(p), nil
+}
+
+// Flush hands the partial line written so far to fn, marked partial
+func (w *lineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.part) > 0 {
+		w.fn(string(w.part), true)
+		w.part = w.part[:0]
+	}
+}
+
+// streamWriter the writer feeding stream output of p into its log buffer
+func (p *Process) streamWriter(stream string) *lineWriter {
+	return newLineWriter(func(line string, partial bool) {
+		p.logs.append(LogLine{Time: time.Now(), Process: p.Name, Stream: stream, Line: line, Partial: partial})
+	})
+}
+
+// Logs the output of a process kept in memory, with since 0 the last lines
+// are returned, otherwise the lines from seq since on, waiting up to wait for
+// new output, next is the seq to pass as since for the following call
+func (m *Manager) Logs(name string, since uint64, lines int, wait time.Duration) ([]LogLine, uint64, error) {
+	proc, ok := m.getProcess(name)
+	if !ok {
+		return nil, 0, ErrProcessNotFound
+	}
+
+	if since == 0 {
+		logs, next := proc.logs.tail(lines)
+		return logs, next, nil
+	}
+
+	logs, next := proc.logs.since(since, wait)
+	return logs, next, nil
+}
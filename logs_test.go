@@ -0,0 +1,67 @@
+package process
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestLogBuffer(t *testing.T) {
+	buf := newLogBuffer()
+	w := newLineWriter(func(line string, partial bool) {
+		buf.append(LogLine{Stream: StreamStdout, Line: line, Partial: partial})
+	})
+	fmt.Fprint(w, "one\r\ntwo\nthr")
+
+	lines, next := buf.tail(10)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "one", lines[0].Line)
+	assert.Equal(t, uint64(3), next)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprint(w, "ee\n")
+	}()
+	lines, next = buf.since(next, time.Second)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, "three", lines[0].Line)
+	assert.Equal(t, uint64(4), next)
+
+	fmt.Fprint(w, "fo")
+	w.Flush()
+	fmt.Fprint(w, "ur\n")
+	lines, _ = buf.since(next, 0)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, LogLine{Seq: 4, Stream: StreamStdout, Line: "fo", Partial: true}, lines[0])
+	assert.Equal(t, "ur", lines[1].Line)
+	assert.False(t, lines[1].Partial)
+}
+
+func TestManager_Logs(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "echo out; echo err >&2"},
+		Dir:     "logs",
+		Restart: RestartPolicy{Mode: RestartNever},
+	})
+	assert.NoError(t, err)
+	<-proc.done
+
+	lines, _, err := manager.Logs("sh", 0, 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, lines, 2)
+
+	var streams = make(map[string]string)
+	for _, line := range lines {
+		streams[line.Stream] = line.Line
+	}
+	assert.Equal(t, map[string]string{StreamStdout: "out", StreamStderr: "err"}, streams)
+}
@@ -0,0 +1,326 @@
+package process
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSearchLimit lines returned by a SearchLogs page
+var DefaultSearchLimit = 100
+
+// backupTimeFormat the timestamp lumberjack puts in the name of rotated files
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// maxLogLine the longest line read back from a log file
+const maxLogLine = 1024 * 1024
+
+// SearchLogs looks up the lines of the log files of a process, the current
+// ones and those rotated away, oldest first. The files are read in time order
+// until the page is complete. Lines written without a timestamp, by the raw
+// format, get the modification time of their file, so Since and Until are
+// rejected for it
+func (m *Manager) SearchLogs(req SearchLogsReq) ([]LogLine, int, error) {
+	proc, ok := m.getProcess(req.Name)
+	if !ok {
+		return nil, 0, ErrProcessNotFound
+	}
+
+	var re *regexp.Regexp
+	if len(req.Pattern) > 0 {
+		var err error
+		if re, err = regexp.Compile(req.Pattern); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var cfg = m.logConfig(proc)
+	if (!req.Since.IsZero() || !req.Until.IsZero()) && !hasLineTime(cfg) {
+		return nil, 0, fmt.Errorf("search %s: lines of the %s log format have no time", proc.Name, LogFormatRaw)
+	}
+
+	var cursors = make([]*logCursor, 0)
+	defer func() {
+		for _, cur := range cursors {
+			cur.Close()
+		}
+	}()
+	for _, file := range m.logFiles(proc, cfg) {
+		if len(req.Stream) > 0 && len(file.stream) > 0 && file.stream != req.Stream {
+			continue
+		}
+		cursors = append(cursors, &logCursor{cfg: cfg, process: proc.Name, stream: file.stream, files: rotatedFiles(file.path)})
+	}
+
+	var limit = req.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	var (
+		lines   = make([]LogLine, 0)
+		matched int
+	)
+	for {
+		// the oldest line of all streams
+		var next *logCursor
+		for _, cur := range cursors {
+			ok, err := cur.peek()
+			if err != nil {
+				return nil, 0, err
+			}
+			if ok && (next == nil || cur.line.Time.Before(next.line.Time)) {
+				next = cur
+			}
+		}
+		if next == nil {
+			return lines, 0, nil
+		}
+
+		line := next.pop()
+		switch {
+		case len(req.Stream) > 0 && len(line.Stream) > 0 && line.Stream != req.Stream:
+		case !req.Since.IsZero() && line.Time.Before(req.Since):
+		case !req.Until.IsZero() && line.Time.After(req.Until):
+		case re != nil && !re.MatchString(line.Line):
+		default:
+			if matched == req.Offset+limit {
+				// there is a following page
+				return lines, matched, nil
+			}
+			if matched >= req.Offset {
+				lines = append(lines, line)
+			}
+			matched++
+		}
+	}
+}
+
+// hasLineTime reports whether the lines written in the format of cfg carry their time
+func hasLineTime(cfg LogConfig) bool {
+	return cfg.Format == LogFormatText || cfg.Format == LogFormatJSON
+}
+
+type logFile struct {
+	path string
+	// stream of the lines in the file, empty when stdout and stderr are merged
+	stream string
+}
+
+// logFiles the log files of proc as configured by cfg
+func (m *Manager) logFiles(proc *Process, cfg LogConfig) []logFile {
+	var (
+		dir     = proc.command().Dir
+		files   = make([]logFile, 0)
+		outFile = logPath(dir, cfg.OutputFile)
+		errFile = logPath(dir, cfg.ErrorFile)
+	)
+
+	if !cfg.DiscardStdout {
+		files = append(files, logFile{path: outFile, stream: StreamStdout})
+	}
+
+	switch {
+	case cfg.DiscardStderr:
+	case cfg.MergeStderr && !cfg.DiscardStdout:
+		files[0].stream = ""
+	default:
+		files = append(files, logFile{path: errFile, stream: StreamStderr})
+	}
+	return files
+}
+
+// rotatedFiles the backups lumberjack made of filename, oldest first, then
+// filename itself
+func rotatedFiles(filename string) []string {
+	var (
+		dir     = filepath.Dir(filename)
+		ext     = filepath.Ext(filename)
+		prefix  = strings.TrimSuffix(filepath.Base(filename), ext) + "-"
+		backups = make([]string, 0)
+	)
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".gz")
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(backups)
+
+	return append(backups, filename)
+}
+
+// logCursor reads the lines of a log file of a stream and its backups, oldest first
+type logCursor struct {
+	cfg     LogConfig
+	process string
+	stream  string
+	// files left to read
+	files []string
+
+	file    io.Closer
+	gz      io.Closer
+	scanner *bufio.Scanner
+	modTime time.Time
+
+	line LogLine
+	ok   bool
+}
+
+// peek reads the next line into cur.line, false when all files are read
+func (cur *logCursor) peek() (bool, error) {
+	for !cur.ok {
+		if cur.scanner != nil && cur.scanner.Scan() {
+			cur.line = parseLogLine(cur.cfg, cur.scanner.Text())
+			cur.line.Process = cur.process
+			if cur.line.Time.IsZero() {
+				cur.line.Time = cur.modTime
+			}
+			if len(cur.line.Stream) == 0 {
+				cur.line.Stream = cur.stream
+			}
+			cur.ok = true
+			break
+		}
+
+		if cur.scanner != nil {
+			err := cur.scanner.Err()
+			cur.Close()
+			if err != nil {
+				return false, err
+			}
+		}
+		if len(cur.files) == 0 {
+			return false, nil
+		}
+		if err := cur.open(cur.files[0]); err != nil {
+			return false, err
+		}
+		cur.files = cur.files[1:]
+	}
+	return true, nil
+}
+
+// pop the line read by peek
+func (cur *logCursor) pop() LogLine {
+	cur.ok = false
+	return cur.line
+}
+
+// open filename, gunzipped if compressed, a missing file has no lines
+func (cur *logCursor) open(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	var r io.Reader = f
+	if strings.HasSuffix(filename, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		cur.gz = gz
+		r = gz
+	}
+
+	cur.file = f
+	cur.modTime = info.ModTime()
+	cur.scanner = bufio.NewScanner(r)
+	cur.scanner.Buffer(make([]byte, 64*1024), maxLogLine)
+	return nil
+}
+
+// Close the file being read
+func (cur *logCursor) Close() error {
+	if cur.gz != nil {
+		cur.gz.Close()
+		cur.gz = nil
+	}
+	cur.scanner = nil
+	if cur.file == nil {
+		return nil
+	}
+	err := cur.file.Close()
+	cur.file = nil
+	return err
+}
+
+// parseLogLine reads back a line written in the format of cfg, the time and
+// stream are left zero when the line does not carry them
+func parseLogLine(cfg LogConfig, raw string) LogLine {
+	var (
+		line   = LogLine{Line: raw}
+		layout = cfg.TimeFormat
+	)
+	if len(layout) == 0 {
+		layout = time.RFC3339Nano
+	}
+
+	switch cfg.Format {
+	case LogFormatJSON:
+		var obj map[string]interface{}
+		if json.Unmarshal([]byte(raw), &obj) != nil {
+			return line
+		}
+		if s, ok := obj["time"].(string); ok {
+			line.Time, _ = time.Parse(layout, s)
+		}
+		line.Stream, _ = obj["stream"].(string)
+		line.Partial, _ = obj["partial"].(bool)
+		if s, ok := obj["line"].(string); ok {
+			line.Line = s
+		}
+	case LogFormatText:
+		// time, process, stream and the line, the time layout may have spaces
+		var n = strings.Count(layout, " ") + 1
+		parts := strings.SplitN(raw, " ", n+3)
+		if len(parts) < n+2 || !strings.HasSuffix(parts[n+1], ":") {
+			return line
+		}
+		t, err := time.Parse(layout, strings.Join(parts[:n], " "))
+		if err != nil {
+			return line
+		}
+
+		line.Time = t
+		line.Stream = strings.TrimSuffix(parts[n+1], ":")
+		if strings.HasSuffix(line.Stream, partialMark) {
+			line.Stream, line.Partial = strings.TrimSuffix(line.Stream, partialMark), true
+		}
+		line.Line = ""
+		if len(parts) > n+2 {
+			line.Line = parts[n+2]
+		}
+	}
+	return line
+}
@@ -0,0 +1,110 @@
+package process
+
+import (
+	"compress/gzip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestParseLogLine(t *testing.T) {
+	var (
+		at   = time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+		proc = &Process{Name: "api"}
+	)
+
+	for _, cfg := range []LogConfig{
+		{Format: LogFormatText},
+		{Format: LogFormatText, TimeFormat: "2006-01-02 15:04:05"},
+		{Format: LogFormatJSON},
+	} {
+		raw := newLogFormatter(proc, StreamStderr, cfg).format(at, "a b: c", false)
+		line := parseLogLine(cfg, string(raw[:len(raw)-1]))
+		assert.True(t, at.Equal(line.Time), cfg.Format)
+		assert.Equal(t, StreamStderr, line.Stream)
+		assert.Equal(t, "a b: c", line.Line)
+		assert.False(t, line.Partial)
+
+		raw = newLogFormatter(proc, StreamStderr, cfg).format(at, "a b", true)
+		line = parseLogLine(cfg, string(raw[:len(raw)-1]))
+		assert.Equal(t, StreamStderr, line.Stream)
+		assert.True(t, line.Partial, cfg.Format)
+	}
+
+	line := parseLogLine(LogConfig{}, "plain")
+	assert.True(t, line.Time.IsZero())
+	assert.Equal(t, "plain", line.Line)
+}
+
+func TestManager_SearchLogs(t *testing.T) {
+	manager := NewManager(&ManagerConfig{WorkerDir: "./tmp"})
+	defer manager.Stop()
+	go manager.Run()
+
+	os.RemoveAll("./tmp/search")
+	assert.NoError(t, os.MkdirAll("./tmp/search", 0755))
+
+	// a rotated, compressed backup from an earlier run
+	rotatedAt := time.Now().UTC().Add(-time.Hour)
+	f, err := os.Create("./tmp/search/sh-" + rotatedAt.Format(backupTimeFormat) + ".out.gz")
+	assert.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(rotatedAt.Add(-time.Minute).Format(time.RFC3339) + " sh stdout: old match\n"))
+	gz.Close()
+	f.Close()
+
+	proc, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "echo match 1; echo other; echo match 2 >&2; echo match 3"},
+		Dir:     "search",
+		Restart: RestartPolicy{Mode: RestartNever},
+		Log:     LogConfig{Format: LogFormatText},
+	})
+	assert.NoError(t, err)
+	<-proc.done
+
+	lines, next, err := manager.SearchLogs(SearchLogsReq{Name: "sh", Pattern: "match"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, next)
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "old match", lines[0].Line)
+
+	lines, next, err = manager.SearchLogs(SearchLogsReq{Name: "sh", Pattern: "match", Stream: StreamStdout, Since: time.Now().Add(-time.Minute), Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, next)
+	assert.Equal(t, []string{"match 1"}, logLineTexts(lines))
+
+	lines, next, err = manager.SearchLogs(SearchLogsReq{Name: "sh", Pattern: "match", Stream: StreamStdout, Since: time.Now().Add(-time.Minute), Offset: 1, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, next)
+	assert.Equal(t, []string{"match 3"}, logLineTexts(lines))
+
+	// raw lines have no time of their own
+	raw, err := manager.Start(StartReq{
+		Name:    "echo",
+		Args:    []string{"raw match"},
+		Dir:     "search",
+		Restart: RestartPolicy{Mode: RestartNever},
+	})
+	assert.NoError(t, err)
+	<-raw.done
+
+	lines, _, err = manager.SearchLogs(SearchLogsReq{Name: "echo", Pattern: "match"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"raw match"}, logLineTexts(lines))
+	_, _, err = manager.SearchLogs(SearchLogsReq{Name: "echo", Since: time.Now().Add(-time.Minute)})
+	assert.Error(t, err)
+
+	_, _, err = manager.SearchLogs(SearchLogsReq{Name: "none"})
+	assert.Equal(t, ErrProcessNotFound, err)
+}
+
+func logLineTexts(lines []LogLine) []string {
+	var texts = make([]string, 0)
+	for _, line := range lines {
+		texts = append(texts, line.Line)
+	}
+	return texts
+}
@@ -9,6 +9,7 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fatih/structs"
@@ -24,20 +25,37 @@ type Manager struct {
 	WorkerDir  string
 	ConfigFile string
 	Echo       bool
-
+	Log        LogConfig
+	// MetricsInterval how often the processes are sampled, DefaultMetricsInterval by default
+	MetricsInterval time.Duration
+	// CgroupParent a cgroup v2 delegated to the manager, each process gets a
+	// cgroup below it, processes are not put in cgroups when empty. On linux
+	// 5.7 and later a process is started in its cgroup, otherwise it is moved
+	// right after its start
+	CgroupParent string
+
+	events      *eventBuffer
 	done        chan bool
 	processExit chan *Process
 	processStop chan *Process
 	process     sync.Map
+	// mu serializes Scale and Reload, which add, remove and replace processes
+	mu sync.Mutex
 }
 
 type ManagerConfig struct {
-	Filename  string
-	WorkerDir string
-	Procs     []Process
-	Echo      bool
+	Filename        string
+	WorkerDir       string
+	Procs           []Process
+	Echo            bool
+	Log             LogConfig
+	MetricsInterval time.Duration
+	CgroupParent    string
 }
 
+// LivenessInterval how often Run looks for running processes which are gone
+const LivenessInterval = 10 * time.Second
+
 var (
 	DefaultConfig = ManagerConfig{Filename: "process.yaml", WorkerDir: "./run"}
 	// DefaultManager = NewManager(&DefaultConfig)
@@ -54,12 +72,16 @@ func NewManager(cfg *ManagerConfig) *Manager {
 	}
 
 	m := &Manager{
-		ConfigFile:  cfg.Filename,
-		WorkerDir:   cfg.WorkerDir,
-		Echo:        cfg.Echo,
-		done:        make(chan bool),
-		processExit: make(chan *Process),
-		processStop: make(chan *Process),
+		ConfigFile:      cfg.Filename,
+		WorkerDir:       cfg.WorkerDir,
+		Echo:            cfg.Echo,
+		Log:             cfg.Log,
+		MetricsInterval: cfg.MetricsInterval,
+		CgroupParent:    cfg.CgroupParent,
+		events:          newEventBuffer(),
+		done:            make(chan bool),
+		processExit:     make(chan *Process),
+		processStop:     make(chan *Process),
 	}
 
 	if len(cfg.Filename) > 0 {
@@ -71,76 +93,244 @@ func NewManager(cfg *ManagerConfig) *Manager {
 
 // StartProcess starts a process
 func (m *Manager) StartProcess(name string, binary string, args []string, env []string, dir string) (*Process, error) {
-	// var (
-	// 	// cwd, _  = os.Getwd()
-	// 	fullbin string
-	// 	err     error
-	// )
-
-	// if IsRelPath(name) {
-	// 	fullbin, err = filepath.Abs(name)
-	// 	if err != nil {
-	// 		return nil, err
-	// 	}
-	// } else {
-	// 	fullbin = name
-	// }
-
-	// name = filepath.Base(name)
-
-	// if err != nil {
-	// 	return nil, err
-	// }
+	return m.Start(StartReq{Name: name, Binary: binary, Args: args, Env: env, Dir: dir})
+}
+
+// Start starts a process described by req, a req with Instances starts a
+// cluster and returns its first instance
+func (m *Manager) Start(req StartReq) (*Process, error) {
+	if req.Instances > 1 {
+		procs, err := m.startCluster(req)
+		if err != nil {
+			return nil, err
+		}
+		return procs[0], nil
+	}
+
+	proc, err := m.newProcess(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.waitDependencies(proc); err != nil {
+		return nil, err
+	}
+
+	process, err := m.runProcess(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	m.process.Store(req.Name, process)
+
+	return process, m.SaveConfig()
+}
+
+// newProcess builds the command and process described by req without starting it
+func (m *Manager) newProcess(req StartReq) (*Process, error) {
 	var fullbin string
-	if len(binary) > 0 {
-		fullbin = binary
+	if len(req.Binary) > 0 {
+		fullbin = req.Binary
 	} else {
-		fullbin = name
+		fullbin = req.Name
 	}
 	var (
-		cmd     = exec.Command(fullbin, args...)
-		fulldir = path.Join(m.WorkerDir, dir)
+		cmd     = exec.Command(fullbin, req.Args...)
+		fulldir = path.Join(m.WorkerDir, req.Dir)
 	)
 	log.Debugf("fulldir %s", fulldir)
 
-	os.MkdirAll(fulldir, 0755)
+	resolved, err := req.Credential.resolve()
+	if err != nil {
+		return nil, err
+	}
+	if resolved != nil {
+		if err := validateCredentialDir(req.Dir); err != nil {
+			return nil, err
+		}
+	}
 
 	cmd.Dir = fulldir
-	if env == nil {
-		cmd.Env = os.Environ()
-	} else {
-		cmd.Env = env
+	env, err := buildEnv(req, fulldir)
+	if err != nil {
+		return nil, err
 	}
+	cmd.Env = env
 
-	process, err := m.runProcess(NewProcess(name, cmd, nil))
+	if resolved != nil {
+		if err := setCredential(cmd, resolved); err != nil {
+			return nil, err
+		}
+		cmd.Env = credentialEnv(cmd.Env, resolved)
+	}
+
+	proc := NewProcess(req.Name, cmd, nil)
+	proc.Dir = req.Dir
+	proc.Env = req.Env
+	proc.EnvFile = req.EnvFile
+	proc.EnvMode = req.EnvMode
+	proc.EnvAllow = req.EnvAllow
+	if err := req.Restart.validate(); err != nil {
+		return nil, err
+	}
+	proc.Restart = req.Restart.WithDefaults()
+	if len(req.StopSignal) > 0 {
+		if _, err := ParseSignal(req.StopSignal); err != nil {
+			return nil, err
+		}
+		proc.StopSignal = req.StopSignal
+	}
+	if req.StopTimeout > 0 {
+		proc.StopTimeout = req.StopTimeout
+	}
+	if err := req.HealthCheck.validate(); err != nil {
+		return nil, err
+	}
+	proc.HealthCheck = req.HealthCheck
+	proc.DependsOn = req.DependsOn
+	proc.RestartOnDependency = req.RestartOnDependency
+	proc.Instances = req.Instances
+	proc.Port = req.Port
+	proc.Log = req.Log
+	triggers, err := newTriggerSet(req.Triggers)
 	if err != nil {
 		return nil, err
 	}
+	proc.Triggers = req.Triggers
+	proc.triggers = triggers
+	if err := req.Thresholds.validate(); err != nil {
+		return nil, err
+	}
+	proc.Thresholds = req.Thresholds
+	proc.Cgroup = req.Cgroup
+	proc.Credential = req.Credential
+	if err := req.Tuning.validate(); err != nil {
+		return nil, err
+	}
+	proc.Tuning = req.Tuning
+	if err := req.Sandbox.validate(fulldir, m.WorkerDir); err != nil {
+		return nil, err
+	}
+	proc.Sandbox = req.Sandbox
+	if err := req.Security.validate(fulldir); err != nil {
+		return nil, err
+	}
+	proc.Security = req.Security
 
-	m.process.Store(name, process)
+	// only a valid process gets its dir
+	os.MkdirAll(fulldir, 0755)
+	if resolved != nil {
+		chownDir(fulldir, resolved)
+	}
 
-	return process, m.SaveConfig()
+	return proc, nil
 }
 
-// RestartProcess restarts a process
+// RestartProcess restarts a process, a fatal or stopped process is brought back under supervision
 func (m *Manager) RestartProcess(name string) error {
 	process, ok := m.getProcess(name)
 	if !ok {
 		return ErrProcessNotFound
 	}
-	log.Infof("restart process name %s", name)
+
+	unlock := process.lock()
+	process.restarts = nil
+	unlock()
+	process.daemon.Store(1)
+	if process.stopped() {
+		process.setStopped(false)
+		m.SaveConfig()
+	}
+
+	return m.restartProcess(process)
+}
+
+func (m *Manager) restartProcess(process *Process) error {
+	return m.restartFor(process, ExitReasonRestarted)
+}
+
+// restartFor restarts a process, reason goes into the exit status of the stopped run
+func (m *Manager) restartFor(process *Process, reason string) error {
+	log.Infof("restart process name %s", process.Name)
+	process.incRestarts()
+	old, done := process.current()
 	// R: Running S: Sleep T: Stop I: Idle Z: Zombie W: Wait L: Lock The character is same within all supported platforms.
 	switch process.Status() {
-	case "R", "S", "I", "W", "L": // Running
-		process.Kill()
-	case "T", "Z": // Stopped
-		process.Kill()
+	case "R", "S", "I", "W", "L", "T", "Z": // Running or Stopped
+		// swap the command first, so the exit of the old one is not taken as a crash
+		process.setCommand(Clone(old))
+		process.setState(StateStopping)
+		process.setExitReason(reason)
+		if err := m.terminate(process, old, done); err != nil {
+			return err
+		}
+		<-done
+		if process.daemon.Load() == 0 {
+			// stopped while it was restarting
+			process.setState(StateStopped)
+			return nil
+		}
+	default:
+		process.setCommand(Clone(old))
 	}
 
-	process.cmd = Clone(process.cmd)
-	_, err := m.runProcess(process)
+	if _, err := m.runProcess(process); err != nil {
+		return err
+	}
 
-	return err
+	m.cascadeRestart(process)
+	return nil
+}
+
+// scheduleRestart restarts an exited process after the backoff of its policy,
+// or marks it fatal once it restarted too often within the policy window
+func (m *Manager) scheduleRestart(process *Process) {
+	if process.daemon.Load() == 0 {
+		return
+	}
+
+	var (
+		policy  = process.Restart.WithDefaults()
+		cmd     = process.command()
+		success = cmd.ProcessState != nil && cmd.ProcessState.Success()
+		now     = time.Now()
+	)
+
+	if process.Health() == HealthUnhealthy {
+		// stopped by the health check, a clean exit is still a failure
+		success = false
+	}
+
+	if !policy.ShouldRestart(success) {
+		log.Infof("process %s exited, restart policy %s", process.Name, policy.Mode)
+		process.daemon.Store(0)
+		return
+	}
+
+	unlock := process.lock()
+	restarts := policy.recentRestarts(process.restarts, now)
+	if policy.MaxRestarts > 0 && len(restarts) >= policy.MaxRestarts {
+		process.restarts = restarts
+		unlock()
+		log.Errorf("process %s restarted %d times within %s, mark fatal", process.Name, len(restarts), policy.Window)
+		process.daemon.Store(0)
+		process.setState(StateFatal)
+		return
+	}
+
+	delay := policy.Backoff(len(restarts))
+	process.restarts = append(restarts, now)
+	unlock()
+	process.setState(StateBackoff)
+	log.Infof("restart process %s in %s", process.Name, delay)
+
+	time.AfterFunc(delay, func() {
+		if process.daemon.Load() > 0 && process.State() == StateBackoff {
+			if err := m.restartProcess(process); err != nil {
+				log.Errorf("restart process %s error %s", process.Name, err)
+			}
+		}
+	})
 }
 
 // Clone copying cmd struct
@@ -151,6 +341,7 @@ func Clone(cmd *exec.Cmd) *exec.Cmd {
 	cmd2.Env = cmd.Env
 	cmd2.ExtraFiles = cmd.ExtraFiles
 	cmd2.Dir = cmd.Dir
+	cmd2.SysProcAttr = cmd.SysProcAttr
 	return cmd2
 }
 
@@ -175,67 +366,146 @@ func (m *Manager) LoadProcesses(filename string) error {
 		m.ConfigFile = configFile
 	}
 
+	if logm, ok := mm["Log"]; ok {
+		m.Log = loadLogConfig(logm)
+	}
+
+	if interval := toDuration(mm["MetricsInterval"]); interval > 0 {
+		m.MetricsInterval = interval
+	}
+
+	if parent, ok := mm["CgroupParent"].(string); ok {
+		m.CgroupParent = parent
+	}
+
+	var loaded = make([]*Process, 0)
 	if procs, ok := mm["Procs"].([]interface{}); ok {
 		for _, pm := range procs {
 			if mmm, ok := pm.(map[string]interface{}); ok {
 				log.Infof("pm %v", mmm)
-				proc := m.loadProc(mmm)
-				if proc == nil {
-					log.Errorf("load process is nil")
-					continue
-				}
-
-				proc, err := m.runProcess(proc)
+				procs, err := m.loadProc(mmm)
 				if err != nil {
-					log.Errorf("run process %s error %s", proc.Name, err)
+					log.Errorf("load process error %s", err)
 					continue
 				}
-				m.process.Store(proc.Name, proc)
-				// m.
+				loaded = append(loaded, procs...)
 			}
 		}
 	}
 
+	loaded, err = sortProcesses(loaded)
+	if err != nil {
+		return err
+	}
+
+	for _, proc := range loaded {
+		proc.loadHistory()
+		if proc.stopped() && proc.Restart.Mode == RestartUnlessStopped {
+			log.Infof("process %s stopped by user, skip it", proc.Name)
+			proc.daemon.Store(0)
+			proc.setState(StateStopped)
+			m.process.Store(proc.Name, proc)
+			continue
+		}
+
+		if err := m.waitDependencies(proc); err != nil {
+			log.Errorf("start process %s error %s", proc.Name, err)
+			continue
+		}
+
+		if running, ok := m.findRunning(proc); ok {
+			// survived the previous manager, adopt it instead of starting a copy
+			if err := m.adoptProcess(proc, running); err != nil {
+				log.Errorf("adopt process %s error %s", proc.Name, err)
+				continue
+			}
+			m.process.Store(proc.Name, proc)
+			continue
+		}
+
+		if _, err := m.runProcess(proc); err != nil {
+			log.Errorf("run process %s error %s", proc.Name, err)
+			continue
+		}
+		m.process.Store(proc.Name, proc)
+	}
+
 	return nil
 }
 
-func (m *Manager) loadProc(pm map[string]interface{}) *Process {
+// loadProc loads the processes of a config entry, an entry declaring
+// Instances expands into its cluster instances
+func (m *Manager) loadProc(pm map[string]interface{}) ([]*Process, error) {
 	var (
-		name   string
-		binary string
-		args   []string
-		env    []string
-		dir    string
-		ok     bool
+		req StartReq
+		ok  bool
 	)
 
-	if name, ok = pm["Name"].(string); !ok {
-		return nil
-	}
-
-	if binary, ok = pm["Binary"].(string); !ok {
-		return nil
-	}
-
-	args, ok = convert.SliceString(pm["Args"])
-	env, ok = convert.SliceString(pm["Env"])
-	dir, ok = pm["Dir"].(string)
+	if req.Name, ok = pm["Name"].(string); !ok {
+		return nil, fmt.Errorf("process name is missing")
+	}
+
+	if req.Binary, ok = pm["Binary"].(string); !ok {
+		return nil, fmt.Errorf("process %s binary is missing", req.Name)
+	}
+
+	req.Args, _ = convert.SliceString(pm["Args"])
+	if env, _ := convert.SliceString(pm["Env"]); len(env) > 0 {
+		req.Env = env
+	}
+	req.EnvFile, _ = convert.SliceString(pm["EnvFile"])
+	req.EnvMode, _ = pm["EnvMode"].(string)
+	req.EnvAllow, _ = convert.SliceString(pm["EnvAllow"])
+	req.Dir, _ = pm["Dir"].(string)
+	req.Restart = loadRestartPolicy(pm["Restart"])
+	req.StopSignal, _ = pm["StopSignal"].(string)
+	req.StopTimeout = toDuration(pm["StopTimeout"])
+	req.HealthCheck = loadHealthCheck(pm["HealthCheck"])
+	req.DependsOn, _ = convert.SliceString(pm["DependsOn"])
+	req.RestartOnDependency, _ = convert.Bool(pm["RestartOnDependency"])
+	req.Instances, _ = convert.Int(pm["Instances"])
+	req.Port, _ = convert.Int(pm["Port"])
+	req.Log = loadLogConfig(pm["Log"])
+	req.Triggers = loadTriggers(pm["Triggers"])
+	req.Thresholds = loadThresholds(pm["Thresholds"])
+	req.Cgroup = loadCgroupConfig(pm["Cgroup"])
+	req.Credential = loadCredential(pm["Credential"])
+	req.Tuning = loadTuning(pm["Tuning"])
+	req.Sandbox = loadSandbox(pm["Sandbox"])
+	req.Security = loadSecurity(pm["Security"])
 
 	var (
-		cmd     = exec.Command(binary, args...)
-		fulldir = path.Join(m.WorkerDir, dir)
+		procs      []*Process
+		err        error
+		stopped, _ = convert.Bool(pm["Stopped"])
 	)
-	log.Debugf("fulldir %s", fulldir)
-	os.MkdirAll(fulldir, 0755)
 
-	cmd.Dir = fulldir
-	if env == nil {
-		cmd.Env = os.Environ()
+	if group, ok := pm["Group"].(string); ok && len(group) > 0 {
+		// an instance saved by SaveConfig
+		instance, _ := convert.Int(pm["Instance"])
+		req.Name = group
+
+		proc, err := m.newInstance(req, instance)
+		if err != nil {
+			return nil, err
+		}
+		procs = []*Process{proc}
+	} else if req.Instances > 1 {
+		if procs, err = m.newInstances(req, 0, req.Instances); err != nil {
+			return nil, err
+		}
 	} else {
-		cmd.Env = env
+		proc, err := m.newProcess(req)
+		if err != nil {
+			return nil, err
+		}
+		procs = []*Process{proc}
 	}
 
-	return NewProcess(name, cmd, nil)
+	for _, proc := range procs {
+		proc.Stopped = stopped
+	}
+	return procs, nil
 }
 
 // SaveConfig save all processes config to a file
@@ -250,7 +520,9 @@ func (m *Manager) SaveConfig() error {
 	)
 	m.process.Range(func(key, value interface{}) bool {
 		proc := value.(*Process)
+		unlock := proc.lock()
 		procs = append(procs, structs.Map(proc))
+		unlock()
 		return true
 	})
 
@@ -276,17 +548,26 @@ func (r *inputReader) Read(p []byte) (n int, err error) {
 
 // runProcess runs a process
 func (m *Manager) runProcess(pproc *Process) (*Process, error) {
-	cmd := pproc.cmd
+	cmd := pproc.command()
+	pproc.setState(StateStarting)
+	pproc.setExitReason("")
+	pproc.loadHistory()
 	var g = new(errgroup.Group)
-	stdout, err := cmd.StdoutPipe()
+	// plain pipes instead of StdoutPipe, cmd.Wait would close them before
+	// the output is drained
+	stdout, stdoutW, err := os.Pipe()
 	if err != nil {
 		return nil, err
 	}
 
-	stderr, err := cmd.StderrPipe()
+	stderr, stderrW, err := os.Pipe()
 	if err != nil {
+		stdout.Close()
+		stdoutW.Close()
 		return nil, err
 	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -296,56 +577,136 @@ func (m *Manager) runProcess(pproc *Process) (*Process, error) {
 		io.WriteString(stdin, "\n")
 	}()
 
-	if err = cmd.Start(); err != nil {
+	// own process group, so stop and restart signal the whole child tree
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	setProcessGroup(cmd.SysProcAttr)
+
+	enter, release, err := m.cgroupStart(pproc, cmd)
+	if err == nil {
+		err = startSandboxed(cmd, pproc, enter)
+		release()
+	}
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		// not retried, a restart of a process failing to start fails alike
+		pproc.setState(StateFatal)
 		return nil, err
 	}
-	name := path.Base(cmd.Path)
+	proc, err := process.NewProcess(int32(cmd.Process.Pid))
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, err
+	}
+
+	var (
+		done    = make(chan struct{})
+		copied  = make(chan struct{})
+		outputs sync.WaitGroup
+		startAt = time.Now()
+		tail    = newTailWriter(DefaultStderrTail)
+	)
+	pproc.setRun(proc, done)
+
+	if len(pproc.HealthCheck.Kind()) > 0 {
+		// running once the first health check passes
+		pproc.health.Store(string(HealthStarting))
+		go m.watchHealth(pproc, cmd, done)
+	} else if pproc.triggers.hasReady() {
+		// running once the ready trigger matches
+		pproc.health.Store(string(HealthStarting))
+	} else {
+		pproc.health.Store(string(HealthNone))
+		pproc.setState(StateRunning)
+	}
+	name := pproc.fileName("")
+	out := m.openOutputs(pproc, cmd, tail)
+	unlock := pproc.lock()
+	pproc.outputs = out
+	unlock()
+
+	outputs.Add(2)
+	go func() {
+		outputs.Wait()
+		out.Close()
+		close(copied)
+	}()
 
 	g.Go(func() error {
-		log.Infof("create '%s' out file to %s", name, cmd.Dir+"/"+name+".out")
-		var logger = m.createLogger(cmd.Dir, name+".out")
-		defer logger.Close()
-		var w io.Writer = logger
-		if m.Echo {
-			w = io.MultiWriter(logger, os.Stdout)
-		}
-		_, err := io.Copy(w, stdout)
+		defer outputs.Done()
+		defer stdout.Close()
+		_, err := io.Copy(out.stdout, stdout)
 		return err
 	})
 
 	g.Go(func() error {
-		log.Infof("create '%s' err file to %s", name, cmd.Dir+"/"+name+".err")
-		var logger = m.createLogger(cmd.Dir, name+".err")
-		defer logger.Close()
-		var w io.Writer = logger
-		if m.Echo {
-			w = io.MultiWriter(logger, os.Stdout)
-		}
-		_, err := io.Copy(w, stderr)
+		defer outputs.Done()
+		defer stderr.Close()
+		_, err := io.Copy(out.stderr, stderr)
 		return err
 	})
 
-	proc, err := process.NewProcess(int32(cmd.Process.Pid))
-	if err != nil {
-		return nil, err
-	}
-
-	pproc.Process = proc
-
 	err = m.createPidfile(cmd, cmd.Dir, name+".pid")
 	if err != nil {
+		// not managed without its pid file, don't leave it running
+		signalGroup(cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+		pproc.setState(StateFatal)
 		return nil, err
 	}
 
-	pproc.OutputFile = path.Join(cmd.Dir, name+".out")
-	pproc.ErrorFile = path.Join(cmd.Dir, name+".err")
+	unlock = pproc.lock()
 	pproc.PidFile = path.Join(cmd.Dir, name+".pid")
+	unlock()
 
 	// pproc := &Process{Name: cmd.Args[0], cmd: cmd, g: g, Process: proc}
 
 	g.Go(func() error {
 		err := cmd.Wait()
+		select {
+		case <-copied:
+		case <-time.After(time.Second):
+			// a leftover child still holds the output open
+		}
+		status := exitStatusOf(cmd.ProcessState)
+		if status != nil {
+			if reason := pproc.exitReason.Load(); len(reason) > 0 {
+				status.Reason = reason
+				pproc.setExitReason("")
+			} else if pproc.cgroup.oomKilled() {
+				status.Reason = ExitReasonOOM
+			}
+
+			var (
+				endAt  = time.Now()
+				record = RunRecord{
+					Pid:     int32(cmd.Process.Pid),
+					StartAt: startAt,
+					EndAt:   endAt,
+					Runtime: endAt.Sub(startAt),
+					Exit:    *status,
+				}
+			)
+			if !cmd.ProcessState.Success() && status.Reason != ExitReasonStopped && status.Reason != ExitReasonRestarted {
+				record.Stderr = tail.Lines()
+			}
+			pproc.recordRun(record)
+		}
+		current := pproc.isCurrent(cmd)
+		if current {
+			pproc.setExited(status)
+		}
+		close(done)
 		// inputExit <- true
+		if !current {
+			// replaced by a restart, the new command reports its own exit
+			return err
+		}
 		m.processExit <- pproc
 		return err
 	})
@@ -353,15 +714,13 @@ func (m *Manager) runProcess(pproc *Process) (*Process, error) {
 	return pproc, nil
 }
 
-func (m *Manager) createLogger(dir, nameAndExt string) io.WriteCloser {
-	var output = path.Join(dir, nameAndExt)
-
+func (m *Manager) createLogger(filename string, cfg LogConfig) *lumberjack.Logger {
 	return &lumberjack.Logger{
-		Filename:   output,
-		MaxSize:    500, // megabytes
-		MaxBackups: 3,
-		MaxAge:     28,   //days
-		Compress:   true, // disabled by default
+		Filename:   filename,
+		MaxSize:    cfg.MaxSize, // megabytes
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge, //days
+		Compress:   !cfg.NoCompress,
 	}
 }
 
@@ -375,27 +734,70 @@ func (m *Manager) createPidfile(cmd *exec.Cmd, dir, nameAndExt string) error {
 	return nil
 }
 
-// StopProcess stops a process
+// StopProcess stops a process, it sends the stop signal and returns without
+// waiting, the process group is killed if it does not exit within StopTimeout
 func (m *Manager) StopProcess(name string) error {
 	proc, ok := m.getProcess(name)
 	if !ok {
 		return ErrProcessNotFound
 	}
 
-	runing, err := proc.IsRunning()
+	_, err := m.stopProcess(proc)
+	return err
+}
+
+// StopProcessWait stops a process like StopProcess, and blocks until it really exited
+func (m *Manager) StopProcessWait(name string) (*ExitStatus, error) {
+	proc, ok := m.getProcess(name)
+	if !ok {
+		return nil, ErrProcessNotFound
+	}
+
+	done, err := m.stopProcess(proc)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	<-done
+
+	return proc.LastExit(), nil
+}
+
+// stopProcess stops proc for the user, it is persisted as Stopped
+func (m *Manager) stopProcess(proc *Process) (<-chan struct{}, error) {
+	proc.setStopped(true)
+	if err := m.SaveConfig(); err != nil {
+		log.Errorf("save config error %s", err)
+	}
+
+	return m.stopFor(proc, ExitReasonStopped)
+}
+
+// stopFor stops proc and records reason as the exit reason of its run, unlike
+// stopProcess the process is not taken as stopped by the user
+func (m *Manager) stopFor(proc *Process, reason string) (<-chan struct{}, error) {
+	cmd, done := proc.current()
 
 	m.processStop <- proc
 	proc.daemon.Store(0)
-	if runing {
-		if err := proc.cmd.Process.Signal(os.Interrupt); err != nil {
-			return err
+	if !running(done) {
+		// never started or the run is over, nothing to wait for
+		proc.setState(StateStopped)
+		if done == nil {
+			done = make(chan struct{})
+			close(done)
 		}
+		return done, nil
 	}
 
-	return nil
+	proc.setState(StateStopping)
+	proc.setExitReason(reason)
+	if !running(done) {
+		// exited before it saw the stop request
+		proc.compareAndSetState(StateStopping, StateStopped)
+		return done, nil
+	}
+
+	return done, m.terminate(proc, cmd, done)
 }
 
 // RemoveProcess removes a process
@@ -406,12 +808,76 @@ func (m *Manager) RemoveProcess(name string) error {
 	}
 	m.processStop <- proc
 	proc.daemon.Store(0)
+	proc.setState(StateStopping)
+	proc.setExitReason(ExitReasonStopped)
 	m.process.Delete(proc.Name)
-	proc.cmd.Process.Signal(os.Interrupt)
+	if cmd, done := proc.current(); done != nil {
+		if err := m.terminate(proc, cmd, done); err != nil {
+			log.Errorf("stop process %s error %s", proc.Name, err)
+		}
+
+		go func(done <-chan struct{}) {
+			<-done
+			if err := proc.cgroup.remove(); err != nil {
+				log.Errorf("remove cgroup of %s error %s", proc.Name, err)
+			}
+		}(done)
+	}
 
 	return m.SaveConfig()
 }
 
+// terminate sends the stop signal of proc to the process group of cmd, then
+// SIGKILL to the group and the cgroup if done is not closed within the stop timeout
+func (m *Manager) terminate(proc *Process, cmd *exec.Cmd, done <-chan struct{}) error {
+	return m.terminateGroup(proc, cmd, done, proc.cgroup)
+}
+
+// terminateGroup terminates like terminate, with cg killed after the timeout,
+// nil spares the cgroup
+func (m *Manager) terminateGroup(proc *Process, cmd *exec.Cmd, done <-chan struct{}, cg *cgroup) error {
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+	if cmd.Process == nil {
+		// swapped in by a restart, which terminates the run of done itself
+		return nil
+	}
+
+	sig, err := ParseSignal(proc.StopSignal)
+	if err != nil {
+		return err
+	}
+
+	var (
+		pid     = cmd.Process.Pid
+		timeout = proc.StopTimeout
+	)
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+
+	log.Infof("stop process %s with %s", proc.Name, signalName(sig))
+	if err := signalGroup(pid, sig); err != nil {
+		return err
+	}
+
+	go func() {
+		select {
+		case <-done:
+		case <-time.After(timeout):
+			log.Errorf("process %s not exited after %s, kill it", proc.Name, timeout)
+			signalGroup(pid, syscall.SIGKILL)
+			// children which left the process group
+			cg.kill()
+		}
+	}()
+
+	return nil
+}
+
 func (m *Manager) getProcess(name string) (*Process, bool) {
 	var val, ok = m.process.Load(name)
 	if !ok {
@@ -422,12 +888,8 @@ func (m *Manager) getProcess(name string) (*Process, bool) {
 	return proc, ok
 }
 
-// AttachProcess attachs a process
-func (m *Manager) AttachProcess(id int) error {
-	panic("nonimplement")
-}
-
-func (m *Manager) AllStatus() ([]*Process, error) {
+// Processes all managed processes
+func (m *Manager) Processes() []*Process {
 	var processes = make([]*Process, 0)
 	m.process.Range(func(key, value interface{}) bool {
 		// convert value to *Process and append to processes
@@ -435,7 +897,17 @@ func (m *Manager) AllStatus() ([]*Process, error) {
 		return true
 	})
 
-	return processes, nil
+	return processes
+}
+
+// AllStatus the typed status of all managed processes
+func (m *Manager) AllStatus() ([]ProcessStatus, error) {
+	var statuses = make([]ProcessStatus, 0)
+	for _, proc := range m.Processes() {
+		statuses = append(statuses, proc.Snapshot())
+	}
+
+	return statuses, nil
 }
 
 // Run startup manager
@@ -452,29 +924,36 @@ func (m *Manager) Run() error {
 		m.processExit = make(chan *Process)
 	}
 
+	stop := make(chan struct{})
+	defer close(stop)
+	go m.runSampler(stop)
+
+	liveness := time.NewTicker(LivenessInterval)
+	defer liveness.Stop()
+
 	for {
 		select {
 		case process := <-m.processExit:
 			log.Infof("exit process %s", process.Name)
 			// m.process.Delete(process.Name)
-			if process.daemon.Load() > 0 {
-				go func() {
-					if process.daemon.Load() > 0 {
-						time.Sleep(5 * time.Second)
-						m.RestartProcess(process.Name)
-					}
-				}()
-			}
+			m.scheduleRestart(process)
 		case process := <-m.processStop:
 			log.Infof("stop process %s", process.Name)
 			// m.process.Delete(process.Name)
 			// process.daemon.Store(0)
-		case <-time.After(10 * time.Second):
+		case <-liveness.C:
 			m.process.Range(func(key, value interface{}) bool {
 				if proc, ok := value.(*Process); ok {
+					if proc.daemon.Load() == 0 {
+						return true
+					}
+					if state := proc.State(); state != StateRunning && state != StateStarting {
+						// exits are handled by scheduleRestart
+						return true
+					}
 					switch proc.Status() {
 					case "E":
-						m.RestartProcess(proc.Name)
+						m.restartProcess(proc)
 					}
 				}
 				return true
@@ -485,7 +964,11 @@ func (m *Manager) Run() error {
 	}
 }
 
+// Stop stops the running processes, dependants before their dependencies,
+// and ends Run, the processes are not marked stopped by the user so they are
+// started again when the manager loads its config
 func (m *Manager) Stop() error {
+	m.shutdown()
 	m.done <- true
 	return nil
 }
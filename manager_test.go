@@ -3,7 +3,9 @@ package process
 import (
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/shirou/gopsutil/process"
 	"github.com/tj/assert"
 )
 
@@ -58,3 +60,86 @@ func TestManager_StartProcess(t *testing.T) {
 	_, ok := manager.getProcess("ls")
 	assert.True(t, ok)
 }
+
+func TestManager_StopProcessWait(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:        "sh",
+		Args:        []string{"-c", "trap '' INT TERM; sleep 10"},
+		Dir:         "sh",
+		StopSignal:  "SIGTERM",
+		StopTimeout: 200 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, StateRunning, proc.State())
+
+	status, err := manager.StopProcessWait("sh")
+	assert.NoError(t, err)
+	assert.NotNil(t, status)
+	assert.Equal(t, "SIGKILL", status.Signal)
+
+	snapshot := proc.Snapshot()
+	assert.Equal(t, StateStopped, snapshot.State)
+	assert.Equal(t, status, snapshot.LastExit)
+	assert.Contains(t, snapshot.Transitions, StateStopping)
+}
+
+func TestManager_StopExited(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:    "exited",
+		Binary:  "true",
+		Dir:     "exited",
+		Restart: RestartPolicy{Mode: RestartNever},
+	})
+	assert.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, StateExited, proc.State())
+
+	_, err = manager.StopProcessWait("exited")
+	assert.NoError(t, err)
+	assert.Equal(t, StateStopped, proc.State())
+	assert.True(t, proc.stopped())
+}
+
+func TestManager_ProcessGroup(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:       "sh",
+		Args:       []string{"-c", "sleep 10 & sleep 10; wait"},
+		Dir:        "sh",
+		StopSignal: "SIGTERM",
+	})
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	children := proc.Descendants()
+	assert.Len(t, children, 2)
+
+	_, err = manager.StopProcessWait("sh")
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	for _, child := range children {
+		// orphans may linger as zombies until init reaps them
+		if proc, err := process.NewProcess(child.Pid); err == nil {
+			status, _ := proc.Status()
+			assert.Equal(t, "Z", status)
+		}
+	}
+}
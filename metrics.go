@@ -0,0 +1,195 @@
+package process
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+var (
+	// DefaultMetricsInterval how often the processes are sampled
+	DefaultMetricsInterval = 5 * time.Second
+	// DefaultMetricsHistory samples kept per process, an hour at the default interval
+	DefaultMetricsHistory = 720
+)
+
+// MetricsSample the resource usage of a process and its child tree at a time
+type MetricsSample struct {
+	Time time.Time
+	Pid  int32
+	// Processes in the tree, the process itself included
+	Processes int
+	// CPUSeconds user and system time used by the live processes of the tree,
+	// or by all processes of its cgroup
+	CPUSeconds float64
+	// CPUPercent of one core used since the previous sample
+	CPUPercent float64
+	RSS        uint64
+	VMS        uint64
+	// MemoryCurrent memory charged to the cgroup of the process, page cache
+	// included, 0 when it has no cgroup
+	MemoryCurrent uint64
+	ReadBytes     uint64
+	WriteBytes    uint64
+	FDs           int32
+	Threads       int32
+}
+
+// metricsRing a bounded history of samples
+type metricsRing struct {
+	mu      sync.Mutex
+	samples []MetricsSample
+	start   int
+}
+
+func (r *metricsRing) add(sample MetricsSample) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.samples) < DefaultMetricsHistory {
+		r.samples = append(r.samples, sample)
+		return
+	}
+	r.samples[r.start] = sample
+	r.start = (r.start + 1) % len(r.samples)
+}
+
+// since the samples taken after t, oldest first
+func (r *metricsRing) since(t time.Time) []MetricsSample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var samples = make([]MetricsSample, 0)
+	for i := range r.samples {
+		sample := r.samples[(r.start+i)%len(r.samples)]
+		if sample.Time.After(t) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+func (r *metricsRing) last() *MetricsSample {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.samples) == 0 {
+		return nil
+	}
+	sample := r.samples[(r.start+len(r.samples)-1)%len(r.samples)]
+	return &sample
+}
+
+// sampleTree the usage of proc and its descendants
+func sampleTree(proc *process.Process) MetricsSample {
+	var sample = MetricsSample{Time: time.Now(), Pid: proc.Pid}
+
+	var walk func(p *process.Process)
+	walk = func(p *process.Process) {
+		sample.Processes++
+		if times, err := p.Times(); err == nil {
+			sample.CPUSeconds += times.User + times.System
+		}
+		if mem, err := p.MemoryInfo(); err == nil {
+			sample.RSS += mem.RSS
+			sample.VMS += mem.VMS
+		}
+		if counters, err := p.IOCounters(); err == nil {
+			sample.ReadBytes += counters.ReadBytes
+			sample.WriteBytes += counters.WriteBytes
+		}
+		if fds, err := p.NumFDs(); err == nil {
+			sample.FDs += fds
+		}
+		if threads, err := p.NumThreads(); err == nil {
+			sample.Threads += threads
+		}
+
+		children, _ := p.Children()
+		for _, child := range children {
+			walk(child)
+		}
+	}
+	walk(proc)
+
+	return sample
+}
+
+// sampleMetrics takes a sample of p, when it runs
+func (p *Process) sampleMetrics() {
+	if p.metrics == nil {
+		return
+	}
+	if state := p.State(); state != StateRunning && state != StateStarting {
+		return
+	}
+
+	proc := p.handle()
+	if proc == nil {
+		return
+	}
+
+	sample := sampleTree(proc)
+	p.cgroup.usage(&sample)
+	if prev := p.metrics.last(); prev != nil && prev.Pid == sample.Pid {
+		if elapsed := sample.Time.Sub(prev.Time).Seconds(); elapsed > 0 && sample.CPUSeconds >= prev.CPUSeconds {
+			sample.CPUPercent = (sample.CPUSeconds - prev.CPUSeconds) / elapsed * 100
+		}
+	}
+	p.metrics.add(sample)
+}
+
+// LastSample the latest metrics sample of the process, nil before the first one
+func (p *Process) LastSample() *MetricsSample {
+	if p.metrics == nil {
+		return nil
+	}
+	return p.metrics.last()
+}
+
+// sampleMetrics samples all running processes and checks their thresholds
+func (m *Manager) sampleMetrics() {
+	for _, proc := range m.Processes() {
+		proc.sampleMetrics()
+		m.checkThresholds(proc)
+	}
+}
+
+// runSampler samples the processes every metrics interval until stop is
+// closed, a sample slower than the interval delays the next one
+func (m *Manager) runSampler(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.metricsInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.sampleMetrics()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// metricsInterval the sampling interval of the manager
+func (m *Manager) metricsInterval() time.Duration {
+	if m.MetricsInterval > 0 {
+		return m.MetricsInterval
+	}
+	return DefaultMetricsInterval
+}
+
+// Metrics the samples of a process taken after since, oldest first, the last
+// one is the current usage
+func (m *Manager) Metrics(name string, since time.Time) ([]MetricsSample, error) {
+	proc, ok := m.getProcess(name)
+	if !ok {
+		return nil, ErrProcessNotFound
+	}
+
+	if proc.metrics == nil {
+		return []MetricsSample{}, nil
+	}
+	return proc.metrics.since(since), nil
+}
@@ -0,0 +1,61 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestMetricsRing(t *testing.T) {
+	defaultHistory := DefaultMetricsHistory
+	DefaultMetricsHistory = 3
+	defer func() { DefaultMetricsHistory = defaultHistory }()
+
+	var (
+		ring = new(metricsRing)
+		now  = time.Now()
+	)
+	assert.Nil(t, ring.last())
+
+	for i := 0; i < 5; i++ {
+		ring.add(MetricsSample{Time: now.Add(time.Duration(i) * time.Second), Processes: i})
+	}
+
+	samples := ring.since(time.Time{})
+	assert.Len(t, samples, 3)
+	assert.Equal(t, 2, samples[0].Processes)
+	assert.Equal(t, 4, ring.last().Processes)
+	assert.Len(t, ring.since(now.Add(3*time.Second)), 1)
+}
+
+func TestManager_Metrics(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir:       "./tmp",
+		MetricsInterval: 50 * time.Millisecond,
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	_, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "sleep 10 & sleep 10"},
+		Dir:     "metrics",
+		Restart: RestartPolicy{Mode: RestartNever},
+	})
+	assert.NoError(t, err)
+	defer manager.StopProcessWait("sh")
+
+	time.Sleep(200 * time.Millisecond)
+	samples, err := manager.Metrics("sh", time.Time{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, samples)
+
+	last := samples[len(samples)-1]
+	assert.GreaterOrEqual(t, last.Processes, 2)
+	assert.NotZero(t, last.RSS)
+	assert.NotZero(t, last.Threads)
+
+	_, err = manager.Metrics("none", time.Time{})
+	assert.Equal(t, ErrProcessNotFound, err)
+}
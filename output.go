@@ -0,0 +1,283 @@
+package process
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/hysios/log"
+	"github.com/hysios/utils/convert"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// LogConfig where the output of a process goes and how its log files rotate,
+// zero fields of a process config fall back to the manager config, then to
+// DefaultLogConfig
+type LogConfig struct {
+	// MaxSize megabytes of a log file before it is rotated
+	MaxSize int
+	// MaxBackups rotated files kept
+	MaxBackups int
+	// MaxAge days rotated files are kept
+	MaxAge     int
+	NoCompress bool
+
+	// OutputFile and ErrorFile are relative to the process dir, or absolute,
+	// set in the manager config they are prefixed with the process name
+	OutputFile string
+	ErrorFile  string
+	// MergeStderr writes stderr into the stdout log file
+	MergeStderr   bool
+	DiscardStdout bool
+	DiscardStderr bool
+
+	// Format of the lines written to the log files, LogFormatRaw by default
+	Format string
+	// TimeFormat layout of the line timestamps, time.RFC3339Nano by default
+	TimeFormat string
+
+	// Syslog forwards the lines to syslog too
+	Syslog SyslogConfig
+	// Journald forwards the lines to systemd-journald too
+	Journald bool
+}
+
+var DefaultLogConfig = LogConfig{
+	MaxSize:    500,
+	MaxBackups: 3,
+	MaxAge:     28,
+}
+
+// merge fills the zero fields of cfg from base
+func (cfg LogConfig) merge(base LogConfig) LogConfig {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = base.MaxSize
+	}
+	if cfg.MaxBackups <= 0 {
+		cfg.MaxBackups = base.MaxBackups
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = base.MaxAge
+	}
+	if len(cfg.OutputFile) == 0 {
+		cfg.OutputFile = base.OutputFile
+	}
+	if len(cfg.ErrorFile) == 0 {
+		cfg.ErrorFile = base.ErrorFile
+	}
+	if len(cfg.Format) == 0 {
+		cfg.Format = base.Format
+	}
+	if len(cfg.TimeFormat) == 0 {
+		cfg.TimeFormat = base.TimeFormat
+	}
+	if !cfg.Syslog.Enabled() {
+		cfg.Syslog = base.Syslog
+	}
+	cfg.NoCompress = cfg.NoCompress || base.NoCompress
+	cfg.MergeStderr = cfg.MergeStderr || base.MergeStderr
+	cfg.DiscardStdout = cfg.DiscardStdout || base.DiscardStdout
+	cfg.DiscardStderr = cfg.DiscardStderr || base.DiscardStderr
+	cfg.Journald = cfg.Journald || base.Journald
+	return cfg
+}
+
+func loadLogConfig(v interface{}) LogConfig {
+	var cfg LogConfig
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return cfg
+	}
+
+	cfg.MaxSize, _ = convert.Int(pm["MaxSize"])
+	cfg.MaxBackups, _ = convert.Int(pm["MaxBackups"])
+	cfg.MaxAge, _ = convert.Int(pm["MaxAge"])
+	cfg.NoCompress, _ = convert.Bool(pm["NoCompress"])
+	cfg.OutputFile, _ = pm["OutputFile"].(string)
+	cfg.ErrorFile, _ = pm["ErrorFile"].(string)
+	cfg.MergeStderr, _ = convert.Bool(pm["MergeStderr"])
+	cfg.DiscardStdout, _ = convert.Bool(pm["DiscardStdout"])
+	cfg.DiscardStderr, _ = convert.Bool(pm["DiscardStderr"])
+	cfg.Format, _ = pm["Format"].(string)
+	cfg.TimeFormat, _ = pm["TimeFormat"].(string)
+	cfg.Syslog = loadSyslogConfig(pm["Syslog"])
+	cfg.Journald, _ = convert.Bool(pm["Journald"])
+
+	return cfg
+}
+
+// outputs the writers of the current run of a process
+type outputs struct {
+	mu     sync.Mutex
+	files  []*lumberjack.Logger
+	lines  []*lineWriter
+	sinks  []lineSink
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// Flush hands the unterminated last lines to the line consumers, marked partial
+func (o *outputs) Flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, w := range o.lines {
+		w.Flush()
+	}
+	return nil
+}
+
+// Reopen closes the log files, they are opened again at their path on the
+// next write, so files moved away by logrotate are recreated
+func (o *outputs) Reopen() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var err error
+	for _, f := range o.files {
+		if e := f.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (o *outputs) Close() error {
+	o.Flush()
+	err := o.Reopen()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, sink := range o.sinks {
+		if e := sink.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// openOutputs builds the stdout and stderr writers of the run of pproc by cmd
+// as configured by its log config, tail receives stderr
+func (m *Manager) openOutputs(pproc *Process, cmd *exec.Cmd, tail io.Writer) *outputs {
+	var (
+		cfg     = m.logConfig(pproc)
+		dir     = cmd.Dir
+		out     = &outputs{}
+		stdouts = make([]io.Writer, 0)
+		stderrs = []io.Writer{tail}
+		stdoutL *lumberjack.Logger
+		// the files of the run, set on pproc under its lock
+		outputFile, errorFile string
+	)
+
+	if !cfg.DiscardStdout {
+		outputFile = logPath(dir, cfg.OutputFile)
+		log.Infof("create '%s' out file to %s", pproc.Name, outputFile)
+		stdoutL = m.createLogger(outputFile, cfg)
+		out.files = append(out.files, stdoutL)
+		stdouts = append(stdouts, out.format(pproc, StreamStdout, stdoutL, cfg))
+	}
+
+	switch {
+	case cfg.DiscardStderr:
+	case cfg.MergeStderr && stdoutL != nil:
+		errorFile = outputFile
+		stderrs = append(stderrs, out.format(pproc, StreamStderr, stdoutL, cfg))
+	default:
+		errorFile = logPath(dir, cfg.ErrorFile)
+		log.Infof("create '%s' err file to %s", pproc.Name, errorFile)
+		logger := m.createLogger(errorFile, cfg)
+		out.files = append(out.files, logger)
+		stderrs = append(stderrs, out.format(pproc, StreamStderr, logger, cfg))
+	}
+
+	unlock := pproc.lock()
+	pproc.OutputFile, pproc.ErrorFile = outputFile, errorFile
+	unlock()
+
+	stdoutLines, stderrLines := pproc.streamWriter(StreamStdout), pproc.streamWriter(StreamStderr)
+	out.lines = append(out.lines, stdoutLines, stderrLines)
+	stdouts = append(stdouts, stdoutLines)
+	stderrs = append(stderrs, stderrLines)
+
+	sinkStdouts, sinkStderrs := out.openSinks(pproc, cmd.Process.Pid, cfg)
+	stdouts = append(stdouts, sinkStdouts...)
+	stderrs = append(stderrs, sinkStderrs...)
+
+	triggerStdouts, triggerStderrs := m.openTriggers(pproc, cmd, out)
+	stdouts = append(stdouts, triggerStdouts...)
+	stderrs = append(stderrs, triggerStderrs...)
+
+	if m.Echo {
+		stdouts = append(stdouts, os.Stdout)
+		stderrs = append(stderrs, os.Stdout)
+	}
+
+	out.stdout = io.MultiWriter(stdouts...)
+	out.stderr = io.MultiWriter(stderrs...)
+	return out
+}
+
+// logConfig the log config in effect for pproc, with the file names filled in,
+// the files of the manager config are qualified so processes sharing a dir
+// do not write the same file
+func (m *Manager) logConfig(pproc *Process) LogConfig {
+	var cfg = pproc.Log.merge(m.Log).merge(DefaultLogConfig)
+
+	if len(pproc.Log.OutputFile) == 0 && len(m.Log.OutputFile) > 0 {
+		cfg.OutputFile = qualifyLogFile(pproc, m.Log.OutputFile)
+	}
+	if len(pproc.Log.ErrorFile) == 0 && len(m.Log.ErrorFile) > 0 {
+		cfg.ErrorFile = qualifyLogFile(pproc, m.Log.ErrorFile)
+	}
+	if len(cfg.OutputFile) == 0 {
+		cfg.OutputFile = pproc.fileName(".out")
+	}
+	if len(cfg.ErrorFile) == 0 {
+		cfg.ErrorFile = pproc.fileName(".err")
+	}
+	return cfg
+}
+
+// qualifyLogFile prefixes the base name of filename with the name of pproc
+func qualifyLogFile(pproc *Process, filename string) string {
+	return filepath.Join(filepath.Dir(filename), pproc.Name+"-"+filepath.Base(filename))
+}
+
+func logPath(dir, filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return path.Join(dir, filename)
+}
+
+// FlushLogs hands the buffered partial lines of every process to their
+// consumers, they are marked partial as their rest follows as the next line
+func (m *Manager) FlushLogs() error {
+	var err error
+	for _, proc := range m.Processes() {
+		if out := proc.currentOutputs(); out != nil {
+			if e := out.Flush(); e != nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// ReopenLogs reopens the log files of every process, for an external logrotate
+func (m *Manager) ReopenLogs() error {
+	var err error
+	for _, proc := range m.Processes() {
+		if out := proc.currentOutputs(); out != nil {
+			if e := out.Reopen(); e != nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
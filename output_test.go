@@ -0,0 +1,50 @@
+package process
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestLogConfig_Merge(t *testing.T) {
+	cfg := LogConfig{MaxSize: 10, MergeStderr: true}.
+		merge(LogConfig{MaxBackups: 5, NoCompress: true}).
+		merge(DefaultLogConfig)
+
+	assert.Equal(t, 10, cfg.MaxSize)
+	assert.Equal(t, 5, cfg.MaxBackups)
+	assert.Equal(t, DefaultLogConfig.MaxAge, cfg.MaxAge)
+	assert.True(t, cfg.NoCompress)
+	assert.True(t, cfg.MergeStderr)
+}
+
+func TestManager_MergeStderr(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+		Log:       LogConfig{OutputFile: "all.log"},
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	os.Remove("./tmp/merge/sh-all.log")
+	proc, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "echo out; echo err >&2"},
+		Dir:     "merge",
+		Restart: RestartPolicy{Mode: RestartNever},
+		Log:     LogConfig{MergeStderr: true},
+	})
+	assert.NoError(t, err)
+	<-proc.done
+
+	assert.Equal(t, "tmp/merge/sh-all.log", proc.OutputFile)
+	assert.Equal(t, proc.OutputFile, proc.ErrorFile)
+
+	b, err := os.ReadFile(proc.OutputFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "out\n")
+	assert.Contains(t, string(b), "err\n")
+
+	assert.NoError(t, manager.ReopenLogs())
+}
@@ -2,6 +2,8 @@ package process
 
 import (
 	"os/exec"
+	"path"
+	"sync"
 	"time"
 
 	"github.com/shirou/gopsutil/process"
@@ -11,6 +13,7 @@ import (
 
 type Process struct {
 	*process.Process
+	// OutputFile, ErrorFile and PidFile of the current run, guarded by mu
 	OutputFile string
 	ErrorFile  string
 	PidFile    string
@@ -18,9 +21,65 @@ type Process struct {
 	Binary     string
 	Dir        string
 	Args       []string
+	// Env KEY=VALUE vars set over the env files, ${VAR} in values is interpolated
+	Env []string
+	// EnvFile .env files relative to the process dir, read when the process is defined
+	EnvFile []string
+	// EnvMode EnvInherit, EnvClean or EnvAllowlist with the patterns of EnvAllow
+	EnvMode  string
+	EnvAllow []string
+	Restart  RestartPolicy
+	// Stopped by the user, guarded by mu
+	Stopped bool
+	// StopSignal is sent to the process group on stop, SIGKILL follows after StopTimeout
+	StopSignal  string
+	StopTimeout time.Duration
+	HealthCheck HealthCheck
+	// DependsOn names the processes which must be ready before this one starts
+	DependsOn []string
+	// RestartOnDependency restarts this process after any of DependsOn restarted
+	RestartOnDependency bool
+	// Instances of the cluster, each instance is named Group-Instance
+	Instances int
+	// Port is the PORT env of instance 0, the next instances count up from it
+	Port     int
+	Group    string
+	Instance int
+	Log      LogConfig
+	// Triggers react to output lines
+	Triggers []Trigger
+	// Thresholds on the resource usage of the process tree
+	Thresholds Thresholds
+	// Cgroup limits, enforced when the manager has a CgroupParent
+	Cgroup CgroupConfig
+	// Credential the user the process runs as
+	Credential Credential
+	// Tuning rlimits, priorities and cpu affinity of the process
+	Tuning Tuning
+	// Sandbox the namespaces and mounts isolating the process
+	Sandbox Sandbox
+	// Security capabilities, no_new_privs and seccomp profile of the process
+	Security Security
+
 	daemon     atomic.Int32
-	cmd        *exec.Cmd
-	g          *errgroup.Group
+	health     atomic.String
+	state      *stateMachine
+	history    *runHistory
+	logs       *logBuffer
+	outputs    *outputs
+	triggers   *triggerSet
+	metrics    *metricsRing
+	thresholds *thresholdState
+	// cgroup is never replaced, its fields are guarded by its own mutex
+	cgroup     *cgroup
+	exitReason atomic.String
+	// mu guards the run fields below and the embedded Process, which are
+	// replaced on every restart
+	mu       *sync.Mutex
+	restarts []time.Time
+	done     chan struct{}
+	cmd      *exec.Cmd
+	g        *errgroup.Group
 }
 
 func Processes() ([]*Process, error) {
@@ -32,24 +91,121 @@ func Processes() ([]*Process, error) {
 
 	for _, process := range _processes {
 		name, _ := process.Name()
-		processes = append(processes, &Process{Process: process, Name: name})
+		processes = append(processes, &Process{Process: process, Name: name, mu: new(sync.Mutex)})
 	}
 
 	return processes, nil
 }
 
 func NewProcess(name string, cmd *exec.Cmd, proc *process.Process) *Process {
-	p := &Process{Name: name, cmd: cmd, Process: proc}
+	p := &Process{Name: name, cmd: cmd, Process: proc, mu: new(sync.Mutex)}
 	p.Args = cmd.Args[1:]
 	p.Binary = cmd.Path
+	p.Restart = DefaultRestartPolicy
+	p.StopSignal = DefaultStopSignal
+	p.StopTimeout = DefaultStopTimeout
+	p.state = newStateMachine()
+	p.history = new(runHistory)
+	p.logs = newLogBuffer()
+	p.metrics = new(metricsRing)
+	p.thresholds = new(thresholdState)
+	p.cgroup = new(cgroup)
 	p.daemon.Inc()
 
 	return p
 }
 
+// lock locks the run fields of p, call the returned func to unlock
+func (p *Process) lock() func() {
+	if p.mu == nil {
+		return func() {}
+	}
+	p.mu.Lock()
+	return p.mu.Unlock
+}
+
+// command the command of the current run
+func (p *Process) command() *exec.Cmd {
+	defer p.lock()()
+	return p.cmd
+}
+
+// current the command of the current run and the channel closed when it exits
+func (p *Process) current() (*exec.Cmd, chan struct{}) {
+	defer p.lock()()
+	return p.cmd, p.done
+}
+
+// isCurrent reports whether cmd is the command of the current run, it is
+// replaced when the process restarts
+func (p *Process) isCurrent(cmd *exec.Cmd) bool {
+	defer p.lock()()
+	return p.cmd == cmd
+}
+
+// setCommand replaces the command of the process, the next run starts it
+func (p *Process) setCommand(cmd *exec.Cmd) {
+	defer p.lock()()
+	p.cmd = cmd
+}
+
+// setRun makes proc the current run of the process, done is closed when it exits
+func (p *Process) setRun(proc *process.Process, done chan struct{}) {
+	defer p.lock()()
+	p.Process = proc
+	p.done = done
+}
+
+// stopped reports whether the process was stopped by the user
+func (p *Process) stopped() bool {
+	defer p.lock()()
+	return p.Stopped
+}
+
+// setStopped records whether the process was stopped by the user, it is
+// persisted with the config
+func (p *Process) setStopped(stopped bool) {
+	defer p.lock()()
+	p.Stopped = stopped
+}
+
+// currentOutputs the outputs of the current run, nil when it never started
+func (p *Process) currentOutputs() *outputs {
+	defer p.lock()()
+	return p.outputs
+}
+
+// handle a gopsutil handle of the current run, nil when it never started,
+// the handle is not shared so concurrent callers don't race on its cache
+func (p *Process) handle() *process.Process {
+	unlock := p.lock()
+	if p.Process == nil {
+		unlock()
+		return nil
+	}
+	pid := p.Process.Pid
+	unlock()
+
+	return &process.Process{Pid: pid}
+}
+
+// fileName the base name of the log, pid and history files of the process
+func (p *Process) fileName(ext string) string {
+	if len(p.Group) > 0 {
+		// instances share the dir, name the files after the instance
+		return p.Name + ext
+	}
+	return path.Base(p.command().Path) + ext
+}
+
 // StartAt procss start time
 func (p *Process) StartAt() time.Time {
-	startAt, err := p.CreateTime()
+	proc := p.handle()
+	if proc == nil {
+		return time.Time{}
+	}
+
+	startAt, err := proc.CreateTime()
 	if err != nil {
 		return time.Time{}
 	}
@@ -57,16 +213,73 @@ func (p *Process) StartAt() time.Time {
 	return time.Unix(startAt/1000, startAt%1000*1000)
 }
 
-// Status process run status
+// Status process run status reported by the OS, "E" when it is unavailable,
+// see State for the lifecycle state tracked by the Manager
 func (p *Process) Status() string {
-	if p.cmd == nil {
+	proc := p.handle()
+	if p.command() == nil || proc == nil {
 		return "E"
 	}
 
-	status, err := p.Process.Status()
+	status, err := proc.Status()
 	if err != nil {
 		return "E"
 	}
 
 	return status
 }
+
+// ProcessNode a live process in the descendant tree of a managed process
+type ProcessNode struct {
+	Pid      int32
+	Name     string
+	Cmdline  string
+	Children []ProcessNode
+}
+
+// Descendants the live child tree of the process, grandchildren included
+func (p *Process) Descendants() []ProcessNode {
+	proc := p.handle()
+	if proc == nil {
+		return nil
+	}
+
+	return descendants(proc)
+}
+
+func descendants(proc *process.Process) []ProcessNode {
+	children, err := proc.Children()
+	if err != nil {
+		return nil
+	}
+
+	var nodes = make([]ProcessNode, 0, len(children))
+	for _, child := range children {
+		name, _ := child.Name()
+		cmdline, _ := child.Cmdline()
+		nodes = append(nodes, ProcessNode{
+			Pid:      child.Pid,
+			Name:     name,
+			Cmdline:  cmdline,
+			Children: descendants(child),
+		})
+	}
+	return nodes
+}
+
+// Health result of the latest health check, HealthNone without a check
+func (p *Process) Health() Health {
+	return Health(p.health.Load())
+}
+
+// Ready reports whether the process is running and, with a health check, healthy
+func (p *Process) Ready() bool {
+	if p.State() != StateRunning {
+		return false
+	}
+
+	if len(p.HealthCheck.Kind()) == 0 {
+		return true
+	}
+	return p.Health() == HealthHealthy
+}
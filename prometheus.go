@@ -0,0 +1,112 @@
+package process
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PrometheusContentType the content type of the text exposition format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var states = []State{
+	StateUnknown, StateStarting, StateRunning, StateBackoff,
+	StateStopping, StateStopped, StateExited, StateFatal,
+}
+
+// metricFamily a metric of the exposition with its samples
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	samples []string
+}
+
+func (f *metricFamily) add(labels string, value float64) {
+	f.samples = append(f.samples, fmt.Sprintf("%s{%s} %g", f.name, labels, value))
+}
+
+func (f *metricFamily) writeTo(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+	for _, sample := range f.samples {
+		w.WriteString(sample + "\n")
+	}
+}
+
+// WritePrometheus writes the metrics of the manager and its processes in the
+// Prometheus text format, the resource usage is the latest sample
+func (m *Manager) WritePrometheus(w io.Writer) error {
+	var (
+		state     = &metricFamily{name: "managed_process_state", help: "Lifecycle state of the process, 1 for the current one.", kind: "gauge"}
+		up        = &metricFamily{name: "managed_process_up", help: "Whether the process is running.", kind: "gauge"}
+		restarts  = &metricFamily{name: "managed_process_restarts_total", help: "Restarts of the process.", kind: "counter"}
+		uptime    = &metricFamily{name: "managed_process_uptime_seconds", help: "Seconds since the current run started.", kind: "gauge"}
+		cpu       = &metricFamily{name: "managed_process_cpu_seconds", help: "User and system CPU time of the live process tree, it drops when children exit.", kind: "gauge"}
+		rss       = &metricFamily{name: "managed_process_resident_memory_bytes", help: "Resident memory of the process tree.", kind: "gauge"}
+		fds       = &metricFamily{name: "managed_process_open_fds", help: "Open file descriptors of the process tree.", kind: "gauge"}
+		lastExit  = &metricFamily{name: "managed_process_last_exit_code", help: "Exit code of the last run.", kind: "gauge"}
+		processes = &metricFamily{name: "process_manager_processes", help: "Managed processes by state.", kind: "gauge"}
+		byState   = make(map[State]int)
+		procs     = m.Processes()
+		now       = time.Now()
+		families  = []*metricFamily{state, up, restarts, uptime, cpu, rss, fds, lastExit, processes}
+		out       = bufio.NewWriter(w)
+	)
+
+	sort.Slice(procs, func(i, j int) bool { return procs[i].Name < procs[j].Name })
+	for _, proc := range procs {
+		var (
+			status = proc.Snapshot()
+			labels = `name="` + escapeLabel(proc.Name) + `",group="` + escapeLabel(proc.Group) + `"`
+		)
+		byState[status.State]++
+
+		for _, s := range states {
+			state.add(labels+`,state="`+string(s)+`"`, boolValue(status.State == s))
+		}
+		up.add(labels, boolValue(status.State == StateRunning))
+		restarts.add(labels, float64(status.Restarts))
+
+		if (status.State == StateRunning || status.State == StateStarting) && !status.StartAt.IsZero() {
+			uptime.add(labels, now.Sub(status.StartAt).Seconds())
+		} else {
+			uptime.add(labels, 0)
+		}
+
+		if sample := proc.LastSample(); sample != nil {
+			cpu.add(labels, sample.CPUSeconds)
+			rss.add(labels, float64(sample.RSS))
+			fds.add(labels, float64(sample.FDs))
+		}
+
+		if status.LastExit != nil {
+			lastExit.add(labels, float64(status.LastExit.Code))
+		}
+	}
+
+	for _, s := range states {
+		processes.add(`state="`+string(s)+`"`, float64(byState[s]))
+	}
+
+	for _, family := range families {
+		family.writeTo(out)
+	}
+	return out.Flush()
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
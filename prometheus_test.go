@@ -0,0 +1,45 @@
+package process
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestManager_WritePrometheus(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir:       "./tmp",
+		MetricsInterval: 50 * time.Millisecond,
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	_, err := manager.Start(StartReq{
+		Name:    "sleep",
+		Args:    []string{"10"},
+		Dir:     "prometheus",
+		Restart: RestartPolicy{Mode: RestartNever},
+	})
+	assert.NoError(t, err)
+	defer manager.StopProcessWait("sleep")
+
+	time.Sleep(200 * time.Millisecond)
+	var buf bytes.Buffer
+	assert.NoError(t, manager.WritePrometheus(&buf))
+
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE managed_process_restarts_total counter\n")
+	assert.Contains(t, out, "# TYPE managed_process_cpu_seconds gauge\n")
+	assert.Contains(t, out, `managed_process_state{name="sleep",group="",state="running"} 1`)
+	assert.Contains(t, out, `managed_process_state{name="sleep",group="",state="fatal"} 0`)
+	assert.Contains(t, out, `managed_process_up{name="sleep",group=""} 1`)
+	assert.Contains(t, out, `managed_process_resident_memory_bytes{name="sleep",group=""}`)
+	assert.Contains(t, out, `process_manager_processes{state="running"} 1`)
+	assert.NotContains(t, out, `managed_process_last_exit_code{name="sleep"`)
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escapeLabel("a\"b\\c\nd"))
+}
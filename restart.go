@@ -0,0 +1,144 @@
+package process
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/hysios/utils/convert"
+)
+
+// RestartMode decides whether an exited process is started again
+type RestartMode string
+
+const (
+	// RestartAlways restarts the process whenever it exits
+	RestartAlways RestartMode = "always"
+	// RestartOnFailure restarts the process only when it exits with an error
+	RestartOnFailure RestartMode = "on-failure"
+	// RestartNever never restarts the process
+	RestartNever RestartMode = "never"
+	// RestartUnlessStopped behaves like RestartAlways, but a process stopped
+	// by the user stays stopped when the manager loads its config again
+	RestartUnlessStopped RestartMode = "unless-stopped"
+)
+
+// RestartPolicy describes how and when an exited process is restarted
+type RestartPolicy struct {
+	Mode RestartMode
+	// Delay is the backoff before the first restart
+	Delay time.Duration
+	// MaxDelay caps the exponential backoff
+	MaxDelay time.Duration
+	// Multiplier grows the delay after every restart within Window
+	Multiplier float64
+	// Jitter randomizes the delay by +/- this fraction, from 0 to 1
+	Jitter float64
+	// MaxRestarts within Window before the process is marked fatal, 0 is unlimited
+	MaxRestarts int
+	Window      time.Duration
+}
+
+var DefaultRestartPolicy = RestartPolicy{
+	Mode:       RestartAlways,
+	Delay:      5 * time.Second,
+	MaxDelay:   5 * time.Minute,
+	Multiplier: 2,
+	Jitter:     0.1,
+	Window:     10 * time.Minute,
+}
+
+// WithDefaults fills the zero fields of policy from DefaultRestartPolicy
+func (policy RestartPolicy) WithDefaults() RestartPolicy {
+	if policy == (RestartPolicy{}) {
+		return DefaultRestartPolicy
+	}
+	if len(policy.Mode) == 0 {
+		policy.Mode = DefaultRestartPolicy.Mode
+	}
+	if policy.Delay <= 0 {
+		policy.Delay = DefaultRestartPolicy.Delay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultRestartPolicy.MaxDelay
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = DefaultRestartPolicy.Multiplier
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		policy.Jitter = DefaultRestartPolicy.Jitter
+	}
+	if policy.Window <= 0 {
+		policy.Window = DefaultRestartPolicy.Window
+	}
+	return policy
+}
+
+func (policy RestartPolicy) validate() error {
+	switch policy.Mode {
+	case "", RestartAlways, RestartOnFailure, RestartNever, RestartUnlessStopped:
+		return nil
+	default:
+		return fmt.Errorf("unknown restart mode %s", policy.Mode)
+	}
+}
+
+// ShouldRestart reports whether a process exited with success should be restarted
+func (policy RestartPolicy) ShouldRestart(success bool) bool {
+	switch policy.Mode {
+	case RestartNever:
+		return false
+	case RestartOnFailure:
+		return !success
+	default:
+		return true
+	}
+}
+
+// Backoff returns the delay before the next restart, given the count of
+// restarts already made within the window
+func (policy RestartPolicy) Backoff(restarts int) time.Duration {
+	delay := float64(policy.Delay) * math.Pow(policy.Multiplier, float64(restarts))
+	if delay > float64(policy.MaxDelay) {
+		delay = float64(policy.MaxDelay)
+	}
+
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// recentRestarts drops the restart times out of the window and returns the rest
+func (policy RestartPolicy) recentRestarts(restarts []time.Time, now time.Time) []time.Time {
+	var recent = restarts[:0]
+	for _, t := range restarts {
+		if now.Sub(t) < policy.Window {
+			recent = append(recent, t)
+		}
+	}
+	return recent
+}
+
+func loadRestartPolicy(v interface{}) RestartPolicy {
+	var policy RestartPolicy
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return policy.WithDefaults()
+	}
+
+	if mode, ok := pm["Mode"].(string); ok {
+		policy.Mode = RestartMode(mode)
+	}
+	policy.Delay = toDuration(pm["Delay"])
+	policy.MaxDelay = toDuration(pm["MaxDelay"])
+	policy.Multiplier, _ = convert.Float(pm["Multiplier"])
+	policy.Jitter, _ = convert.Float(pm["Jitter"])
+	policy.MaxRestarts, _ = convert.Int(pm["MaxRestarts"])
+	policy.Window = toDuration(pm["Window"])
+
+	return policy.WithDefaults()
+}
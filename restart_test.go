@@ -0,0 +1,76 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestRestartPolicy_Backoff(t *testing.T) {
+	policy := RestartPolicy{Delay: time.Second, MaxDelay: 10 * time.Second, Multiplier: 2}.WithDefaults()
+
+	assert.Equal(t, time.Second, policy.Backoff(0))
+	assert.Equal(t, 4*time.Second, policy.Backoff(2))
+	assert.Equal(t, 10*time.Second, policy.Backoff(10))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		delay := policy.Backoff(1)
+		assert.True(t, delay >= time.Second && delay <= 3*time.Second)
+	}
+}
+
+func TestRestartPolicy_ShouldRestart(t *testing.T) {
+	assert.True(t, RestartPolicy{Mode: RestartAlways}.ShouldRestart(true))
+	assert.True(t, RestartPolicy{Mode: RestartUnlessStopped}.ShouldRestart(true))
+	assert.False(t, RestartPolicy{Mode: RestartOnFailure}.ShouldRestart(true))
+	assert.True(t, RestartPolicy{Mode: RestartOnFailure}.ShouldRestart(false))
+	assert.False(t, RestartPolicy{Mode: RestartNever}.ShouldRestart(false))
+}
+
+func TestRestartPolicy_Validate(t *testing.T) {
+	assert.NoError(t, RestartPolicy{}.validate())
+	assert.NoError(t, RestartPolicy{Mode: RestartUnlessStopped}.validate())
+	assert.Error(t, RestartPolicy{Mode: "sometimes"}.validate())
+}
+
+func TestLoadRestartPolicy(t *testing.T) {
+	policy := loadRestartPolicy(map[string]interface{}{
+		"Mode":        "on-failure",
+		"Delay":       "2s",
+		"MaxRestarts": 3,
+		"Window":      "1m0s",
+	})
+
+	assert.Equal(t, RestartOnFailure, policy.Mode)
+	assert.Equal(t, 2*time.Second, policy.Delay)
+	assert.Equal(t, 3, policy.MaxRestarts)
+	assert.Equal(t, time.Minute, policy.Window)
+	assert.Equal(t, DefaultRestartPolicy.MaxDelay, policy.MaxDelay)
+}
+
+func TestManager_RestartLimit(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name: "false",
+		Dir:  "false",
+		Restart: RestartPolicy{
+			Mode:        RestartOnFailure,
+			Delay:       10 * time.Millisecond,
+			MaxRestarts: 2,
+		},
+	})
+	assert.NoError(t, err)
+
+	for i := 0; i < 100 && proc.State() != StateFatal; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, StateFatal, proc.State())
+	assert.Equal(t, 2, proc.Snapshot().Restarts)
+}
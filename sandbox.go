@@ -0,0 +1,126 @@
+package process
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hysios/utils/convert"
+)
+
+// sandboxEnv carries the sandbox spec to the sandbox init in the child
+const sandboxEnv = "_PROCESS_SANDBOX"
+
+var namespaces = []string{"user", "mnt", "pid", "net", "uts", "ipc"}
+
+// Sandbox the linux namespaces and mounts isolating a process
+type Sandbox struct {
+	// Namespaces unshared for the process, of "user", "mnt", "pid", "net",
+	// "uts" and "ipc". In a pid namespace the process is its init with its own
+	// /proc, signals it has no handler for are ignored until the SIGKILL after
+	// StopTimeout. A net namespace has only the loopback, a uts namespace has
+	// the process name as hostname. In a user namespace the process is root
+	// mapped to its Credential user
+	Namespaces []string
+	// PrivateTmp mounts an empty tmpfs on /tmp
+	PrivateTmp bool
+	// ReadOnly paths bind mounted read-only
+	ReadOnly []string
+	// Root a dir relative to the process dir the process is chrooted into,
+	// it has to hold the binary and what it needs
+	Root string
+}
+
+func loadSandbox(v interface{}) Sandbox {
+	var s Sandbox
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return s
+	}
+
+	s.Namespaces, _ = convert.SliceString(pm["Namespaces"])
+	s.PrivateTmp, _ = convert.Bool(pm["PrivateTmp"])
+	s.ReadOnly, _ = convert.SliceString(pm["ReadOnly"])
+	s.Root, _ = pm["Root"].(string)
+
+	return s
+}
+
+func (s Sandbox) empty() bool {
+	return len(s.Namespaces) == 0 && !s.mounts()
+}
+
+func (s Sandbox) has(ns string) bool {
+	for _, name := range s.Namespaces {
+		if name == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// mounts whether a mount namespace is needed for the mounts and chroot
+func (s Sandbox) mounts() bool {
+	return s.PrivateTmp || len(s.ReadOnly) > 0 || len(s.Root) > 0
+}
+
+// validate s of a process in dir, the root must stay under workerDir
+func (s Sandbox) validate(dir, workerDir string) error {
+	for _, ns := range s.Namespaces {
+		var known bool
+		for _, name := range namespaces {
+			known = known || ns == name
+		}
+		if !known {
+			return fmt.Errorf("unknown namespace %s", ns)
+		}
+	}
+
+	for _, path := range s.ReadOnly {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("read-only path %s is not absolute", path)
+		}
+	}
+
+	if len(s.Root) > 0 {
+		root, err := filepath.Abs(s.rootDir(dir))
+		if err != nil {
+			return err
+		}
+		base, err := filepath.Abs(workerDir)
+		if err != nil {
+			return err
+		}
+		if root != base && !strings.HasPrefix(root, base+string(filepath.Separator)) {
+			return fmt.Errorf("sandbox root %s is outside of %s", s.Root, workerDir)
+		}
+	}
+	return nil
+}
+
+// rootDir the chroot dir of a process in dir, empty without one
+func (s Sandbox) rootDir(dir string) string {
+	if len(s.Root) == 0 {
+		return ""
+	}
+	return filepath.Join(dir, s.Root)
+}
+
+// startEntered starts cmd and calls enter, when set, with its pid right after,
+// an error of enter fails the start, the child is reaped
+func startEntered(cmd *exec.Cmd, enter func(pid int) error) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if enter != nil {
+		if err := enter(cmd.Process.Pid); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+	return nil
+}
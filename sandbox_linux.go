@@ -0,0 +1,446 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var cloneFlags = map[string]uintptr{
+	"user": syscall.CLONE_NEWUSER,
+	"mnt":  syscall.CLONE_NEWNS,
+	"pid":  syscall.CLONE_NEWPID,
+	"net":  syscall.CLONE_NEWNET,
+	"uts":  syscall.CLONE_NEWUTS,
+	"ipc":  syscall.CLONE_NEWIPC,
+}
+
+// sandboxSpec what the sandbox init sets up in the child before the exec of
+// the process
+type sandboxSpec struct {
+	Path       string
+	Args       []string
+	Root       string
+	PrivateTmp bool
+	ReadOnly   []string
+	MountProc  bool
+	Loopback   bool
+	Hostname   string
+	// Credential set after the mounts, which need the privileges of the manager
+	Credential *syscall.Credential
+	// DropCapabilities drops all but Capabilities from the bounding set
+	DropCapabilities bool
+	Capabilities     []int
+	NoNewPrivs       bool
+	// Seccomp the compiled filter
+	Seccomp []sockFilter
+	// Tuning applied first, with the privileges of the manager
+	Tuning Tuning
+	// StatusFd the pipe setup errors are written to
+	StatusFd int
+	// ResumeFd the pipe the init waits on before the setup, 0 without
+	ResumeFd int
+}
+
+// mounts whether the init has mounts to set up
+func (spec sandboxSpec) mounts() bool {
+	return spec.PrivateTmp || len(spec.ReadOnly) > 0 || len(spec.Root) > 0 || spec.MountProc
+}
+
+// sandboxMain whether SandboxMain was called, the sandbox init can run
+var sandboxMain bool
+
+// SandboxMain runs the sandbox init when the program is the child of a
+// sandboxed process started by the manager, it sets up the sandbox and execs
+// the process. It returns at once in any other case. Programs running
+// processes with a Sandbox, Security or Tuning config must call it first in
+// main, the children re-execute the program:
+//
+//	func main() {
+//		process.SandboxMain()
+//		...
+//	}
+func SandboxMain() {
+	sandboxMain = true
+
+	data, ok := os.LookupEnv(sandboxEnv)
+	if !ok {
+		return
+	}
+
+	var spec sandboxSpec
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %s\n", err)
+		os.Exit(126)
+	}
+
+	err := sandboxExec(spec)
+	// reported to the manager, which fails the start
+	status := os.NewFile(uintptr(spec.StatusFd), "sandbox status")
+	fmt.Fprint(status, err)
+	os.Exit(126)
+}
+
+// startSandboxed starts cmd of p in its namespaces, the child re-executes
+// the manager binary as the sandbox init, which applies the tuning, sets up
+// the mounts, chroot, user, capabilities and seccomp filter and then execs
+// the process. With enter the init waits for enter to be called with its pid
+// before it goes on, so the process can be put in a cgroup before it runs.
+// An error of enter or of the setup fails the start, the child is reaped.
+// A process without sandbox, security or tuning is started without the init.
+// cmd is left as it was
+func startSandboxed(cmd *exec.Cmd, p *Process, enter func(pid int) error) error {
+	var s = p.Sandbox
+	if s.empty() && p.Security.empty() && p.Tuning.empty() {
+		return startEntered(cmd, enter)
+	}
+	if !sandboxMain {
+		return errors.New("process sandbox requires calling process.SandboxMain first in main")
+	}
+
+	caps, err := p.Security.capabilities()
+	if err != nil {
+		return err
+	}
+
+	var (
+		attr syscall.SysProcAttr
+		spec = sandboxSpec{
+			Path:       cmd.Path,
+			Args:       cmd.Args,
+			Root:       s.rootDir(cmd.Dir),
+			PrivateTmp: s.PrivateTmp,
+			ReadOnly:   s.ReadOnly,
+			MountProc:  s.has("pid"),
+			Loopback:   s.has("net"),
+
+			DropCapabilities: p.Security.DropCapabilities,
+			Capabilities:     caps,
+			NoNewPrivs:       p.Security.NoNewPrivs,
+
+			Tuning: p.Tuning,
+		}
+	)
+	if len(p.Security.Seccomp) > 0 {
+		// loaded at each start, so a changed profile takes on a restart
+		profile, err := loadSeccompProfile(p.Security.seccompPath(cmd.Dir))
+		if err != nil {
+			return err
+		}
+		if spec.Seccomp, err = profile.compile(); err != nil {
+			return err
+		}
+	}
+	if cmd.SysProcAttr != nil {
+		attr = *cmd.SysProcAttr
+	}
+
+	for _, ns := range s.Namespaces {
+		attr.Cloneflags |= cloneFlags[ns]
+	}
+	if spec.mounts() {
+		attr.Cloneflags |= syscall.CLONE_NEWNS
+	}
+	if s.has("uts") {
+		spec.Hostname = hostname(p.Name)
+	}
+
+	spec.Credential = attr.Credential
+	if s.has("user") {
+		var uid, gid = os.Geteuid(), os.Getegid()
+		if attr.Credential != nil {
+			uid, gid = int(attr.Credential.Uid), int(attr.Credential.Gid)
+		}
+		// setgroups is only allowed when the mappings come from a privileged manager
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: gid, Size: 1}}
+		attr.GidMappingsEnableSetgroups = os.Geteuid() == 0
+		spec.Credential = &syscall.Credential{NoSetGroups: !attr.GidMappingsEnableSetgroups}
+	}
+	attr.Credential = nil
+
+	// the init reports setup errors on the status pipe, which is closed on
+	// the exec of the process
+	status, statusW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer status.Close()
+	var files = []*os.File{statusW}
+	spec.StatusFd = 3 + len(cmd.ExtraFiles)
+
+	var resume, resumeR *os.File
+	if enter != nil {
+		// the init blocks on the resume pipe until enter is done
+		if resumeR, resume, err = os.Pipe(); err != nil {
+			statusW.Close()
+			return err
+		}
+		defer resume.Close()
+		files = append(files, resumeR)
+		spec.ResumeFd = spec.StatusFd + 1
+	}
+	closeChildFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	b, err := json.Marshal(spec)
+	if err != nil {
+		closeChildFiles()
+		return err
+	}
+
+	var env = cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+
+	var (
+		path       = cmd.Path
+		args       = cmd.Args
+		origEnv    = cmd.Env
+		orig       = cmd.SysProcAttr
+		extraFiles = cmd.ExtraFiles
+	)
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = args[:1]
+	cmd.Env = append(env[:len(env):len(env)], sandboxEnv+"="+string(b))
+	cmd.SysProcAttr = &attr
+	cmd.ExtraFiles = append(extraFiles[:len(extraFiles):len(extraFiles)], files...)
+
+	err = cmd.Start()
+	closeChildFiles()
+
+	cmd.Path, cmd.Args, cmd.Env, cmd.SysProcAttr, cmd.ExtraFiles = path, args, origEnv, orig, extraFiles
+	if err != nil {
+		return err
+	}
+
+	if enter != nil {
+		if err := enter(cmd.Process.Pid); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+		resume.Write([]byte{0})
+	}
+
+	msg, err := io.ReadAll(status)
+	if err != nil || len(msg) > 0 {
+		cmd.Process.Kill()
+		cmd.Wait()
+		if err == nil {
+			err = fmt.Errorf("sandbox: %s", msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// hostname of a process in its uts namespace
+func hostname(name string) string {
+	name = strings.ReplaceAll(name, "/", "-")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// sandboxExec sets up the sandbox of spec in the current namespaces and execs
+// the process, it only returns on errors
+func sandboxExec(spec sandboxSpec) error {
+	runtime.LockOSThread()
+	syscall.CloseOnExec(spec.StatusFd)
+
+	if spec.ResumeFd > 0 {
+		resume := os.NewFile(uintptr(spec.ResumeFd), "sandbox resume")
+		if _, err := resume.Read(make([]byte, 1)); err != nil {
+			return fmt.Errorf("resume: %w", err)
+		}
+		resume.Close()
+	}
+
+	if !spec.Tuning.empty() {
+		if err := spec.Tuning.apply(); err != nil {
+			return fmt.Errorf("tuning: %w", err)
+		}
+	}
+
+	var root = spec.Root
+	if len(root) == 0 {
+		root = "/"
+	}
+
+	if spec.mounts() {
+		// keep the mounts from propagating back to the host
+		if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+			return fmt.Errorf("make / private: %w", err)
+		}
+	}
+
+	for _, path := range spec.ReadOnly {
+		if err := bindReadOnly(path, filepath.Join(root, path)); err != nil {
+			return err
+		}
+	}
+
+	if spec.PrivateTmp {
+		tmp := filepath.Join(root, "tmp")
+		if err := os.MkdirAll(tmp, 01777); err != nil {
+			return err
+		}
+		if err := syscall.Mount("tmpfs", tmp, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("mount %s: %w", tmp, err)
+		}
+	}
+
+	if spec.MountProc {
+		proc := filepath.Join(root, "proc")
+		if err := os.MkdirAll(proc, 0555); err != nil {
+			return err
+		}
+		if err := syscall.Mount("proc", proc, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+			return fmt.Errorf("mount %s: %w", proc, err)
+		}
+	}
+
+	if len(spec.Hostname) > 0 {
+		if err := syscall.Sethostname([]byte(spec.Hostname)); err != nil {
+			return fmt.Errorf("set hostname: %w", err)
+		}
+	}
+
+	if spec.Loopback {
+		if err := loopbackUp(); err != nil {
+			return fmt.Errorf("loopback up: %w", err)
+		}
+	}
+
+	if len(spec.Root) > 0 {
+		if err := syscall.Chroot(spec.Root); err != nil {
+			return fmt.Errorf("chroot %s: %w", spec.Root, err)
+		}
+		if err := syscall.Chdir("/"); err != nil {
+			return err
+		}
+	}
+
+	if spec.DropCapabilities {
+		if err := dropBounding(spec.Capabilities); err != nil {
+			return err
+		}
+	}
+
+	var cred = spec.Credential
+	if len(spec.Capabilities) > 0 && cred != nil && cred.Uid != 0 {
+		// keep the permitted capabilities over the setuid, for the ambient set
+		if err := prctl(prSetKeepCaps, 1); err != nil {
+			return fmt.Errorf("keep capabilities: %w", err)
+		}
+	}
+
+	if cred != nil {
+		if !cred.NoSetGroups {
+			var groups = make([]int, 0, len(cred.Groups))
+			for _, gid := range cred.Groups {
+				groups = append(groups, int(gid))
+			}
+			if err := syscall.Setgroups(groups); err != nil {
+				return fmt.Errorf("setgroups: %w", err)
+			}
+		}
+		if err := syscall.Setgid(int(cred.Gid)); err != nil {
+			return fmt.Errorf("setgid: %w", err)
+		}
+		if err := syscall.Setuid(int(cred.Uid)); err != nil {
+			return fmt.Errorf("setuid: %w", err)
+		}
+	}
+
+	if len(spec.Capabilities) > 0 {
+		if err := raiseAmbient(spec.Capabilities); err != nil {
+			return err
+		}
+	}
+
+	if spec.NoNewPrivs {
+		if err := prctl(prSetNoNewPrivs, 1); err != nil {
+			return fmt.Errorf("no new privs: %w", err)
+		}
+	}
+
+	if len(spec.Seccomp) > 0 {
+		if err := installSeccomp(spec.Seccomp); err != nil {
+			return fmt.Errorf("seccomp: %w", err)
+		}
+	}
+
+	var env = make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, sandboxEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	return syscall.Exec(spec.Path, spec.Args, env)
+}
+
+// bindReadOnly bind mounts source on target read-only, target is created
+// like source when missing
+func bindReadOnly(source, target string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(target); os.IsNotExist(err) {
+		if info.IsDir() {
+			err = os.MkdirAll(target, 0755)
+		} else if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+			err = os.WriteFile(target, nil, 0644)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", source, err)
+	}
+	// the read-only flag only takes on a remount of the bind
+	if err := syscall.Mount("", target, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", target, err)
+	}
+	return nil
+}
+
+// loopbackUp brings up the loopback of a new net namespace
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var ifr struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}
+	copy(ifr.name[:], "lo")
+	ifr.flags = syscall.IFF_UP | syscall.IFF_RUNNING
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifr))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// SandboxMain returns at once, sandboxes need linux
+func SandboxMain() {}
+
+// startSandboxed starts cmd of p and calls enter with its pid, sandboxes and
+// tuning need linux. An error of enter fails the start, the child is reaped
+func startSandboxed(cmd *exec.Cmd, p *Process, enter func(pid int) error) error {
+	if !p.Sandbox.empty() || !p.Security.empty() {
+		return errors.New("process sandbox is only supported on linux")
+	}
+	if !p.Tuning.empty() {
+		return errors.New("process tuning is only supported on linux")
+	}
+	return startEntered(cmd, enter)
+}
@@ -0,0 +1,98 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/tj/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMain(m *testing.M) {
+	// the children of sandboxed processes re-execute the test binary
+	SandboxMain()
+	os.Exit(m.Run())
+}
+
+func TestSandbox_Validate(t *testing.T) {
+	assert.NoError(t, Sandbox{Namespaces: []string{"pid", "mnt"}, Root: "root"}.validate("tmp/app", "tmp"))
+	assert.Error(t, Sandbox{Namespaces: []string{"cgroup"}}.validate("tmp/app", "tmp"))
+	assert.Error(t, Sandbox{ReadOnly: []string{"etc"}}.validate("tmp/app", "tmp"))
+	assert.Error(t, Sandbox{Root: "../../.."}.validate("tmp/app", "tmp"))
+}
+
+func TestSandbox_Load(t *testing.T) {
+	var sandbox = Sandbox{
+		Namespaces: []string{"pid", "net"},
+		PrivateTmp: true,
+		ReadOnly:   []string{"/etc"},
+		Root:       "root",
+	}
+
+	b, err := yaml.Marshal(structs.Map(struct{ Sandbox Sandbox }{sandbox}))
+	assert.NoError(t, err)
+
+	var mm map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(b, &mm))
+	assert.Equal(t, sandbox, loadSandbox(mm["Sandbox"]))
+}
+
+func TestManager_Sandbox(t *testing.T) {
+	if runtime.GOOS != "linux" || os.Geteuid() != 0 {
+		t.Skip("sandboxes need linux and root")
+	}
+
+	ro, err := filepath.Abs("./tmp/sandbox-ro")
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(ro, 0755))
+
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	_, err = manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", `touch ` + ro + `/x 2>/dev/null && w=writable || w=read-only; echo $$ $(id -u) $(cat /proc/sys/kernel/hostname) $(ls -A /tmp | wc -l) $w; sleep 10`},
+		Dir:     "sandbox",
+		Restart: RestartPolicy{Mode: RestartNever},
+		Sandbox: Sandbox{
+			Namespaces: []string{"user", "pid", "uts", "net", "ipc"},
+			PrivateTmp: true,
+			ReadOnly:   []string{ro},
+		},
+	})
+	assert.NoError(t, err)
+	defer manager.StopProcessWait("sh")
+
+	// the namespaces are set up again for the restarted command
+	var since = uint64(1)
+	for run := 0; run < 2; run++ {
+		if run > 0 {
+			assert.NoError(t, manager.RestartProcess("sh"))
+		}
+
+		lines, next, err := manager.Logs("sh", since, 0, 2*time.Second)
+		assert.NoError(t, err)
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "1 0 sh 0 read-only", lines[0].Line)
+		since = next
+	}
+
+	_, err = os.Stat(filepath.Join(ro, "x"))
+	assert.True(t, os.IsNotExist(err))
+
+	// a sandbox failing to set up fails the start
+	_, err = manager.Start(StartReq{
+		Name:    "true",
+		Dir:     "sandbox",
+		Sandbox: Sandbox{ReadOnly: []string{filepath.Join(ro, "missing")}},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "sandbox: ")
+}
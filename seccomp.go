@@ -0,0 +1,108 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// seccomp filter return values
+const (
+	seccompRetKillProcess = 0x80000000
+	seccompRetKillThread  = 0x00000000
+	seccompRetTrap        = 0x00030000
+	seccompRetErrno       = 0x00050000
+	seccompRetLog         = 0x7ffc0000
+	seccompRetAllow       = 0x7fff0000
+)
+
+var seccompActions = map[string]uint32{
+	"SCMP_ACT_ALLOW":        seccompRetAllow,
+	"SCMP_ACT_ERRNO":        seccompRetErrno,
+	"SCMP_ACT_KILL":         seccompRetKillThread,
+	"SCMP_ACT_KILL_THREAD":  seccompRetKillThread,
+	"SCMP_ACT_KILL_PROCESS": seccompRetKillProcess,
+	"SCMP_ACT_TRAP":         seccompRetTrap,
+	"SCMP_ACT_LOG":          seccompRetLog,
+}
+
+// sockFilter a classic bpf instruction
+type sockFilter struct {
+	Code uint16
+	Jt   uint8
+	Jf   uint8
+	K    uint32
+}
+
+// seccompProfile a syscall profile in the format of the docker profiles,
+// without argument filters. Syscalls unknown on the arch are skipped, the
+// first rule naming a syscall wins
+type seccompProfile struct {
+	DefaultAction   string        `json:"defaultAction"`
+	DefaultErrnoRet *uint32       `json:"defaultErrnoRet"`
+	Syscalls        []seccompRule `json:"syscalls"`
+}
+
+type seccompRule struct {
+	Names    []string          `json:"names"`
+	Name     string            `json:"name"`
+	Action   string            `json:"action"`
+	ErrnoRet *uint32           `json:"errnoRet"`
+	Args     []json.RawMessage `json:"args"`
+}
+
+func loadSeccompProfile(path string) (*seccompProfile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var profile seccompProfile
+	if err := json.Unmarshal(b, &profile); err != nil {
+		return nil, fmt.Errorf("seccomp profile %s: %w", path, err)
+	}
+
+	if _, err := seccompAction(profile.DefaultAction, profile.DefaultErrnoRet); err != nil {
+		return nil, err
+	}
+	for _, rule := range profile.Syscalls {
+		if _, err := seccompAction(rule.Action, rule.ErrnoRet); err != nil {
+			return nil, err
+		}
+		if len(rule.Args) > 0 {
+			return nil, fmt.Errorf("seccomp profile %s: argument filters are not supported", path)
+		}
+	}
+	return &profile, nil
+}
+
+// seccompAction the filter return value of action, like "SCMP_ACT_ERRNO" or
+// "errno", errno is EPERM by default
+func seccompAction(action string, errno *uint32) (uint32, error) {
+	name := strings.ToUpper(action)
+	if !strings.HasPrefix(name, "SCMP_ACT_") {
+		name = "SCMP_ACT_" + name
+	}
+
+	ret, ok := seccompActions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown seccomp action %s", action)
+	}
+	if ret == seccompRetErrno {
+		if errno == nil {
+			ret |= 1 // EPERM
+		} else {
+			ret |= *errno & 0xffff
+		}
+	}
+	return ret, nil
+}
+
+// names the syscalls of r
+func (r seccompRule) names() []string {
+	if len(r.Name) > 0 {
+		return append([]string{r.Name}, r.Names...)
+	}
+	return r.Names
+}
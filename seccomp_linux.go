@@ -0,0 +1,93 @@
+package process
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prSetSeccomp      = 22
+	seccompModeFilter = 2
+
+	// offsets into struct seccomp_data
+	seccompDataNr   = 0
+	seccompDataArch = 4
+
+	// syscalls of the x32 abi on amd64
+	x32SyscallBit = 0x40000000
+
+	bpfLdAbsW = 0x20 // BPF_LD | BPF_W | BPF_ABS
+	bpfJeqK   = 0x15 // BPF_JMP | BPF_JEQ | BPF_K
+	bpfJgeK   = 0x35 // BPF_JMP | BPF_JGE | BPF_K
+	bpfRetK   = 0x06 // BPF_RET | BPF_K
+
+	bpfMaxInsns = 4096
+)
+
+type sockFprog struct {
+	Len    uint16
+	Filter *sockFilter
+}
+
+// compile p into a seccomp filter for the arch of the manager
+func (p *seccompProfile) compile() ([]sockFilter, error) {
+	if seccompArch == 0 {
+		return nil, errors.New("seccomp is not supported on this arch")
+	}
+
+	defaultRet, err := seccompAction(p.DefaultAction, p.DefaultErrnoRet)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter = []sockFilter{
+		// syscalls of other abis are killed, their numbers differ
+		{Code: bpfLdAbsW, K: seccompDataArch},
+		{Code: bpfJeqK, Jt: 1, K: seccompArch},
+		{Code: bpfRetK, K: seccompRetKillProcess},
+		{Code: bpfLdAbsW, K: seccompDataNr},
+	}
+	if seccompArch == 0xc000003e {
+		filter = append(filter,
+			sockFilter{Code: bpfJgeK, Jf: 1, K: x32SyscallBit},
+			sockFilter{Code: bpfRetK, K: seccompRetKillProcess},
+		)
+	}
+
+	var seen = make(map[uint32]bool)
+	for _, rule := range p.Syscalls {
+		ret, err := seccompAction(rule.Action, rule.ErrnoRet)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range rule.names() {
+			nr, ok := syscallNumbers[name]
+			if !ok || seen[nr] {
+				continue
+			}
+			seen[nr] = true
+
+			filter = append(filter,
+				sockFilter{Code: bpfJeqK, Jf: 1, K: nr},
+				sockFilter{Code: bpfRetK, K: ret},
+			)
+		}
+	}
+
+	filter = append(filter, sockFilter{Code: bpfRetK, K: defaultRet})
+	if len(filter) > bpfMaxInsns {
+		return nil, errors.New("seccomp filter too long")
+	}
+	return filter, nil
+}
+
+// installSeccomp loads filter for the calling thread, it is kept over exec
+func installSeccomp(filter []sockFilter) error {
+	var prog = sockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build linux && !amd64 && !arm64
+// +build linux,!amd64,!arm64
+
+package process
+
+// seccompArch zero, the syscall numbers of the arch are unknown
+const seccompArch = 0
+
+var syscallNumbers = map[string]uint32{}
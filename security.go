@@ -0,0 +1,130 @@
+package process
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hysios/utils/convert"
+)
+
+// capabilities the linux capabilities by name
+var capabilities = map[string]int{
+	"CAP_CHOWN":              0,
+	"CAP_DAC_OVERRIDE":       1,
+	"CAP_DAC_READ_SEARCH":    2,
+	"CAP_FOWNER":             3,
+	"CAP_FSETID":             4,
+	"CAP_KILL":               5,
+	"CAP_SETGID":             6,
+	"CAP_SETUID":             7,
+	"CAP_SETPCAP":            8,
+	"CAP_LINUX_IMMUTABLE":    9,
+	"CAP_NET_BIND_SERVICE":   10,
+	"CAP_NET_BROADCAST":      11,
+	"CAP_NET_ADMIN":          12,
+	"CAP_NET_RAW":            13,
+	"CAP_IPC_LOCK":           14,
+	"CAP_IPC_OWNER":          15,
+	"CAP_SYS_MODULE":         16,
+	"CAP_SYS_RAWIO":          17,
+	"CAP_SYS_CHROOT":         18,
+	"CAP_SYS_PTRACE":         19,
+	"CAP_SYS_PACCT":          20,
+	"CAP_SYS_ADMIN":          21,
+	"CAP_SYS_BOOT":           22,
+	"CAP_SYS_NICE":           23,
+	"CAP_SYS_RESOURCE":       24,
+	"CAP_SYS_TIME":           25,
+	"CAP_SYS_TTY_CONFIG":     26,
+	"CAP_MKNOD":              27,
+	"CAP_LEASE":              28,
+	"CAP_AUDIT_WRITE":        29,
+	"CAP_AUDIT_CONTROL":      30,
+	"CAP_SETFCAP":            31,
+	"CAP_MAC_OVERRIDE":       32,
+	"CAP_MAC_ADMIN":          33,
+	"CAP_SYSLOG":             34,
+	"CAP_WAKE_ALARM":         35,
+	"CAP_BLOCK_SUSPEND":      36,
+	"CAP_AUDIT_READ":         37,
+	"CAP_PERFMON":            38,
+	"CAP_BPF":                39,
+	"CAP_CHECKPOINT_RESTORE": 40,
+}
+
+// Security the privileges and syscalls allowed to a process, they are set by
+// the sandbox init in the child before the exec of the process
+type Security struct {
+	// DropCapabilities drops all capabilities but Capabilities from the bounding set
+	DropCapabilities bool
+	// Capabilities kept, like "CAP_NET_BIND_SERVICE", they are raised in the
+	// ambient set so a process run as a non-root user has them too
+	Capabilities []string
+	// NoNewPrivs keeps the process and its children from gaining privileges
+	// through setuid binaries or file capabilities
+	NoNewPrivs bool
+	// Seccomp a JSON syscall profile, relative to the process dir, a process
+	// killed for a denied syscall exits with ExitReasonSeccomp
+	Seccomp string
+}
+
+func loadSecurity(v interface{}) Security {
+	var s Security
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return s
+	}
+
+	s.DropCapabilities, _ = convert.Bool(pm["DropCapabilities"])
+	s.Capabilities, _ = convert.SliceString(pm["Capabilities"])
+	s.NoNewPrivs, _ = convert.Bool(pm["NoNewPrivs"])
+	s.Seccomp, _ = pm["Seccomp"].(string)
+
+	return s
+}
+
+func (s Security) empty() bool {
+	return !s.DropCapabilities && len(s.Capabilities) == 0 && !s.NoNewPrivs && len(s.Seccomp) == 0
+}
+
+// validate s of a process in dir
+func (s Security) validate(dir string) error {
+	if _, err := s.capabilities(); err != nil {
+		return err
+	}
+
+	if len(s.Seccomp) > 0 {
+		if _, err := loadSeccompProfile(s.seccompPath(dir)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capabilities the numbers of the kept capabilities
+func (s Security) capabilities() ([]int, error) {
+	var caps = make([]int, 0, len(s.Capabilities))
+	for _, name := range s.Capabilities {
+		name = strings.ToUpper(name)
+		if !strings.HasPrefix(name, "CAP_") {
+			name = "CAP_" + name
+		}
+
+		c, ok := capabilities[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown capability %s", name)
+		}
+		caps = append(caps, c)
+	}
+	return caps, nil
+}
+
+// seccompPath the seccomp profile of a process in dir
+func (s Security) seccompPath(dir string) string {
+	if filepath.IsAbs(s.Seccomp) {
+		return s.Seccomp
+	}
+	return filepath.Join(dir, s.Seccomp)
+}
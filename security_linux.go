@@ -0,0 +1,83 @@
+package process
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prSetKeepCaps     = 8
+	prCapbsetDrop     = 24
+	prSetNoNewPrivs   = 38
+	prCapAmbient      = 47
+	prCapAmbientRaise = 2
+
+	linuxCapabilityVersion3 = 0x20080522
+)
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+func prctl(option, arg2 uintptr) error {
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, option, arg2, 0, 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// dropBounding drops the capabilities but keep from the bounding set of the thread
+func dropBounding(keep []int) error {
+	var kept = make(map[int]bool)
+	for _, c := range keep {
+		kept[c] = true
+	}
+
+	for c := 0; ; c++ {
+		if kept[c] {
+			continue
+		}
+		if err := prctl(prCapbsetDrop, uintptr(c)); err != nil {
+			if err == syscall.EINVAL {
+				// past the last capability of the kernel
+				return nil
+			}
+			return fmt.Errorf("drop capability %d: %w", c, err)
+		}
+	}
+}
+
+// raiseAmbient makes caps the only capabilities of the thread and raises
+// them in the ambient set, so they are kept over the exec of a non-root user
+func raiseAmbient(caps []int) error {
+	var (
+		header = capHeader{version: linuxCapabilityVersion3}
+		data   [2]capData
+	)
+	for _, c := range caps {
+		data[c/32].effective |= 1 << (uint(c) % 32)
+	}
+	for i := range data {
+		data[i].permitted = data[i].effective
+		data[i].inheritable = data[i].effective
+	}
+
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("capset: %w", errno)
+	}
+
+	for _, c := range caps {
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientRaise, uintptr(c), 0, 0, 0); errno != 0 {
+			return fmt.Errorf("raise ambient capability %d: %w", c, errno)
+		}
+	}
+	return nil
+}
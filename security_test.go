@@ -0,0 +1,122 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/tj/assert"
+	"gopkg.in/yaml.v3"
+)
+
+const testSeccompProfile = `{
+	"defaultAction": "SCMP_ACT_ALLOW",
+	"syscalls": [
+		{"names": ["mkdir", "mkdirat"], "action": "SCMP_ACT_ERRNO", "errnoRet": 13},
+		{"names": ["uname", "no_such_syscall"], "action": "kill_process"}
+	]
+}`
+
+func TestSecurity_Validate(t *testing.T) {
+	assert.NoError(t, os.MkdirAll("./tmp/security", 0755))
+	assert.NoError(t, os.WriteFile("./tmp/security/seccomp.json", []byte(testSeccompProfile), 0644))
+
+	assert.NoError(t, Security{Capabilities: []string{"net_bind_service", "CAP_KILL"}, Seccomp: "seccomp.json"}.validate("./tmp/security"))
+	assert.Error(t, Security{Capabilities: []string{"CAP_FLY"}}.validate("./tmp/security"))
+	assert.Error(t, Security{Seccomp: "missing.json"}.validate("./tmp/security"))
+
+	caps, err := Security{Capabilities: []string{"net_bind_service", "CAP_KILL"}}.capabilities()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{10, 5}, caps)
+}
+
+func TestSecurity_Load(t *testing.T) {
+	var security = Security{
+		DropCapabilities: true,
+		Capabilities:     []string{"CAP_NET_BIND_SERVICE"},
+		NoNewPrivs:       true,
+		Seccomp:          "seccomp.json",
+	}
+
+	b, err := yaml.Marshal(structs.Map(struct{ Security Security }{security}))
+	assert.NoError(t, err)
+
+	var mm map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(b, &mm))
+	assert.Equal(t, security, loadSecurity(mm["Security"]))
+}
+
+func TestSeccompAction(t *testing.T) {
+	var errno uint32 = 38
+
+	ret, err := seccompAction("SCMP_ACT_ERRNO", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(seccompRetErrno|1), ret)
+
+	ret, err = seccompAction("errno", &errno)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(seccompRetErrno|38), ret)
+
+	ret, err = seccompAction("kill_process", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(seccompRetKillProcess), ret)
+
+	_, err = seccompAction("SCMP_ACT_NOTIFY", nil)
+	assert.Error(t, err)
+}
+
+func TestManager_Security(t *testing.T) {
+	if runtime.GOOS != "linux" || (runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64") || os.Geteuid() != 0 {
+		t.Skip("security profiles need linux on amd64 or arm64 and root")
+	}
+
+	dir := filepath.Join("./tmp", "security")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "seccomp.json"), []byte(testSeccompProfile), 0644))
+
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:       "sh",
+		Args:       []string{"-c", `grep -E '^(CapEff|CapBnd|CapAmb|NoNewPrivs)' /proc/self/status | tr -s '\t' ' ' | tr '\n' ' '; echo; mkdir d 2>/dev/null || echo denied; exec uname`},
+		Dir:        "security",
+		Restart:    RestartPolicy{Mode: RestartNever},
+		Credential: Credential{User: "nobody"},
+		Security: Security{
+			DropCapabilities: true,
+			Capabilities:     []string{"CAP_NET_BIND_SERVICE"},
+			NoNewPrivs:       true,
+			Seccomp:          "seccomp.json",
+		},
+	})
+	assert.NoError(t, err)
+
+	select {
+	case <-proc.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("process not exited")
+	}
+
+	lines, _, err := manager.Logs("sh", 0, 10, 0)
+	assert.NoError(t, err)
+	var out []string
+	for _, line := range lines {
+		out = append(out, strings.TrimSpace(line.Line))
+	}
+	assert.Equal(t, []string{
+		"CapEff: 0000000000000400 CapBnd: 0000000000000400 CapAmb: 0000000000000400 NoNewPrivs: 1",
+		"denied",
+	}, out)
+
+	status := proc.Snapshot()
+	assert.Equal(t, ExitReasonSeccomp, status.LastExit.Reason)
+	assert.Equal(t, signalName(seccompSignal), status.LastExit.Signal)
+}
@@ -5,7 +5,6 @@ import (
 	"net/http"
 	"net/rpc"
 
-	"github.com/fatih/structs"
 	"github.com/hysios/log"
 	"github.com/hysios/process"
 )
@@ -24,6 +23,7 @@ func NewServer(addr string, cfg *process.ManagerConfig) *Server {
 func Listen(s *Server) error {
 	rpc.Register(s)
 	rpc.HandleHTTP()
+	http.HandleFunc("/metrics", s.ServeMetrics)
 	l, err := net.Listen("tcp", s.Addr)
 	if err != nil {
 		return err
@@ -33,6 +33,14 @@ func Listen(s *Server) error {
 	return http.Serve(l, nil)
 }
 
+// ServeMetrics serves the metrics of the manager to Prometheus
+func (s *Server) ServeMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", process.PrometheusContentType)
+	if err := s.manager.WritePrometheus(w); err != nil {
+		log.Errorf("write metrics error %s", err)
+	}
+}
+
 type StartReq struct {
 	Name string
 	Args []string
@@ -40,12 +48,21 @@ type StartReq struct {
 	Dir  string
 }
 
-func (s *Server) StartProcess(req process.StartReq, reply *process.Process) error {
-	process, err := s.manager.StartProcess(req.Name, req.Binary, req.Args, req.Env, req.Dir)
+func (s *Server) StartProcess(req process.StartReq, reply *process.ProcessStatus) error {
+	process, err := s.manager.Start(req)
+	if err != nil {
+		return err
+	}
+	*reply = process.Snapshot()
+	return nil
+}
+
+func (s *Server) AttachProcess(pid int, reply *process.ProcessStatus) error {
+	process, err := s.manager.AttachProcess(pid)
 	if err != nil {
 		return err
 	}
-	*reply = *process
+	*reply = process.Snapshot()
 	return nil
 }
 
@@ -58,6 +75,22 @@ func (s *Server) StopProcess(name string, _ *int) error {
 	return nil
 }
 
+func (s *Server) StopProcessWait(name string, reply *process.ExitStatus) error {
+	status, err := s.manager.StopProcessWait(name)
+	if err != nil {
+		return err
+	}
+
+	if status != nil {
+		*reply = *status
+	}
+	return nil
+}
+
+func (s *Server) StopAll(_ int, _ *int) error {
+	return s.manager.StopAll()
+}
+
 func (s *Server) RemoveProcess(name string, _ *int) error {
 	err := s.manager.RemoveProcess(name)
 	if err != nil {
@@ -67,19 +100,86 @@ func (s *Server) RemoveProcess(name string, _ *int) error {
 	return nil
 }
 
-func (s *Server) AllStatus(_ int, status *map[string]interface{}) error {
-	log.Infof("call status")
-	processes, err := s.manager.AllStatus()
+func (s *Server) Scale(req process.ScaleReq, _ *int) error {
+	return s.manager.Scale(req.Name, req.Instances)
+}
+
+func (s *Server) Reload(name string, _ *int) error {
+	return s.manager.Reload(name)
+}
+
+func (s *Server) History(name string, history *[]process.RunRecord) error {
+	records, err := s.manager.History(name)
 	if err != nil {
 		return err
 	}
 
-	for _, proc := range processes {
-		m := structs.Map(proc)
-		m["Status"] = proc.Status()
-		m["StartAt"] = proc.StartAt().Format("2006-01-02 15:04:05")
-		(*status)[proc.Name] = m
+	*history = records
+	return nil
+}
+
+func (s *Server) Logs(req process.LogsReq, reply *process.LogsReply) error {
+	lines, next, err := s.manager.Logs(req.Name, req.Since, req.Lines, req.Wait)
+	if err != nil {
+		return err
+	}
+
+	reply.Lines = lines
+	reply.Next = next
+	return nil
+}
+
+func (s *Server) SearchLogs(req process.SearchLogsReq, reply *process.SearchLogsReply) error {
+	lines, next, err := s.manager.SearchLogs(req)
+	if err != nil {
+		return err
+	}
+
+	reply.Lines = lines
+	reply.Next = next
+	return nil
+}
+
+func (s *Server) Metrics(req process.MetricsReq, samples *[]process.MetricsSample) error {
+	metrics, err := s.manager.Metrics(req.Name, req.Since)
+	if err != nil {
+		return err
+	}
+
+	*samples = metrics
+	return nil
+}
+
+func (s *Server) Events(req process.EventsReq, reply *process.EventsReply) error {
+	reply.Events, reply.Next = s.manager.Events(req.Since, req.Wait)
+	return nil
+}
+
+func (s *Server) FlushLogs(_ int, _ *int) error {
+	return s.manager.FlushLogs()
+}
+
+func (s *Server) ReopenLogs(_ int, _ *int) error {
+	return s.manager.ReopenLogs()
+}
+
+func (s *Server) Describe(name string, reply *process.ProcessDescription) error {
+	desc, err := s.manager.Describe(name)
+	if err != nil {
+		return err
+	}
+
+	*reply = *desc
+	return nil
+}
+
+func (s *Server) AllStatus(_ int, status *[]process.ProcessStatus) error {
+	log.Infof("call status")
+	processes, err := s.manager.AllStatus()
+	if err != nil {
+		return err
 	}
 
+	*status = processes
 	return nil
 }
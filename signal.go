@@ -0,0 +1,98 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	DefaultStopSignal  = "SIGINT"
+	DefaultStopTimeout = 10 * time.Second
+)
+
+// signals the signals known by name, the platform ones are added by signal_unix.go
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// ParseSignal parses a signal name like "SIGTERM", "TERM" or a number like "15"
+func ParseSignal(name string) (syscall.Signal, error) {
+	if len(name) == 0 {
+		name = DefaultStopSignal
+	}
+
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > maxSignal {
+			return 0, fmt.Errorf("signal %d out of 1..%d", n, maxSignal)
+		}
+		return syscall.Signal(n), nil
+	}
+
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	sig, ok := signals[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal %s", name)
+	}
+	return sig, nil
+}
+
+// ExitStatus how a process run ended
+type ExitStatus struct {
+	// Code the exit code, -1 when it is unknown like for an adopted run
+	Code   int
+	Signal string
+	// Reason why the run ended, like ExitReasonStopped or ExitReasonUnhealthy
+	Reason string
+}
+
+func (status ExitStatus) String() string {
+	var s = "exit code " + strconv.Itoa(status.Code)
+	if len(status.Signal) > 0 {
+		s = "signal " + status.Signal
+	}
+
+	switch status.Reason {
+	case "", ExitReasonExited, ExitReasonSignaled:
+		return s
+	default:
+		return status.Reason + ", " + s
+	}
+}
+
+func exitStatusOf(state *os.ProcessState) *ExitStatus {
+	if state == nil {
+		return nil
+	}
+
+	var status = ExitStatus{Code: state.ExitCode(), Reason: ExitReasonExited}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		status.Signal = signalName(ws.Signal())
+		status.Reason = ExitReasonSignaled
+		if ws.Signal() == seccompSignal {
+			// killed by its seccomp filter
+			status.Reason = ExitReasonSeccomp
+		}
+	}
+	return &status
+}
+
+func signalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return name
+		}
+	}
+	return sig.String()
+}
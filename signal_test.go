@@ -0,0 +1,21 @@
+package process
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/tj/assert"
+)
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"SIGTERM", "term", "15"} {
+		sig, err := ParseSignal(name)
+		assert.NoError(t, err)
+		assert.Equal(t, syscall.SIGTERM, sig)
+	}
+
+	for _, name := range []string{"SIGNOPE", "0", "-9", "65"} {
+		_, err := ParseSignal(name)
+		assert.Error(t, err)
+	}
+}
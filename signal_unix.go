@@ -0,0 +1,48 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// seccompSignal the signal a seccomp filter kills with
+const seccompSignal = syscall.SIGSYS
+
+// maxSignal the highest signal number of the OS, SIGRTMAX where it has
+// realtime signals
+var maxSignal = func() int {
+	switch runtime.GOOS {
+	case "linux":
+		return 64
+	case "freebsd":
+		return 128
+	case "netbsd":
+		return 63
+	case "darwin", "ios":
+		return 31
+	default:
+		return 32
+	}
+}()
+
+func init() {
+	signals["SIGUSR1"] = syscall.SIGUSR1
+	signals["SIGUSR2"] = syscall.SIGUSR2
+}
+
+// setProcessGroup makes the child the leader of its own process group
+func setProcessGroup(attr *syscall.SysProcAttr) {
+	attr.Setpgid = true
+}
+
+// signalGroup sends sig to the process group led by pid, or to pid alone when
+// it is not a group leader
+func signalGroup(pid int, sig syscall.Signal) error {
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid {
+		return syscall.Kill(-pid, sig)
+	}
+	return syscall.Kill(pid, sig)
+}
@@ -0,0 +1,27 @@
+package process
+
+import (
+	"os"
+	"syscall"
+)
+
+// seccompSignal never matches, there is no seccomp on windows
+const seccompSignal = syscall.Signal(-1)
+
+// maxSignal the highest signal number known by the syscall package, every
+// signal kills the process on windows
+const maxSignal = int(syscall.SIGTERM)
+
+// setProcessGroup starts the child in a new process group
+func setProcessGroup(attr *syscall.SysProcAttr) {
+	attr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// signalGroup kills pid, windows has no signals to stop a process with
+func signalGroup(pid int, sig syscall.Signal) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return proc.Kill()
+}
@@ -0,0 +1,306 @@
+package process
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hysios/log"
+	"github.com/hysios/utils/convert"
+)
+
+var (
+	// DefaultSyslogAddress the local syslog socket
+	DefaultSyslogAddress = "/dev/log"
+	// DefaultJournaldSocket the native socket of systemd-journald
+	DefaultJournaldSocket = "/run/systemd/journal/socket"
+	// SinkRetryInterval how long lines are dropped after a sink failed to connect
+	SinkRetryInterval = 5 * time.Second
+	// SinkWriteTimeout how long connecting or writing to a sink may block
+	SinkWriteTimeout = time.Second
+	// SinkQueueSize lines queued per sink, more are dropped
+	SinkQueueSize = 1024
+)
+
+// syslog severities of the output streams
+const (
+	severityErr  = 3
+	severityInfo = 6
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// SyslogConfig forwards the output lines to syslog as RFC5424 messages, it
+// is enabled when Network or Address is set
+type SyslogConfig struct {
+	// Network "unixgram", "unix", "udp" or "tcp", "unixgram" by default
+	Network string
+	// Address of the syslog server, DefaultSyslogAddress by default
+	Address string
+	// Facility like "daemon" or "local0", "user" by default
+	Facility string
+	// Tag the app name of the messages, the process name by default
+	Tag string
+}
+
+func (cfg SyslogConfig) Enabled() bool {
+	return len(cfg.Network) > 0 || len(cfg.Address) > 0
+}
+
+func loadSyslogConfig(v interface{}) SyslogConfig {
+	var cfg SyslogConfig
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return cfg
+	}
+
+	cfg.Network, _ = pm["Network"].(string)
+	cfg.Address, _ = pm["Address"].(string)
+	cfg.Facility, _ = pm["Facility"].(string)
+	cfg.Tag, _ = pm["Tag"].(string)
+
+	return cfg
+}
+
+// lineSink receives the output lines of a run of a process
+type lineSink interface {
+	send(t time.Time, stream, line string)
+	Close() error
+}
+
+// sinkConn a datagram or stream connection dialed again after an error, the
+// lines are queued and written by a goroutine so a slow sink does not block
+// the output of the process, they are dropped while the queue is full
+type sinkConn struct {
+	network string
+	address string
+
+	mu     sync.Mutex
+	once   sync.Once
+	queue  chan []byte
+	closed bool
+	// dropped lines in total, reported of them logged
+	dropped  int
+	reported int
+
+	// owned by the drain goroutine
+	timeout time.Duration
+	conn    net.Conn
+	failed  bool
+	retryAt time.Time
+}
+
+func (c *sinkConn) write(b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.once.Do(func() {
+		c.queue, c.timeout = make(chan []byte, SinkQueueSize), SinkWriteTimeout
+		go c.drain(c.queue)
+	})
+
+	select {
+	case c.queue <- b:
+		if c.dropped > c.reported {
+			log.Errorf("log sink %s %s queue full, %d lines dropped", c.network, c.address, c.dropped-c.reported)
+			c.reported = c.dropped
+		}
+	default:
+		c.dropped++
+	}
+}
+
+// drain writes the queued lines until the queue is closed
+func (c *sinkConn) drain(queue <-chan []byte) {
+	for b := range queue {
+		c.send(b)
+	}
+
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+func (c *sinkConn) send(b []byte) {
+	if c.conn == nil {
+		if time.Now().Before(c.retryAt) {
+			return
+		}
+
+		conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+		if err != nil {
+			// report once until it works again
+			if !c.failed {
+				log.Errorf("dial log sink %s %s error %s", c.network, c.address, err)
+			}
+			c.failed = true
+			c.retryAt = time.Now().Add(SinkRetryInterval)
+			return
+		}
+		c.conn, c.failed = conn, false
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(b); err != nil {
+		log.Errorf("write log sink %s %s error %s", c.network, c.address, err)
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// Close stops queueing lines, the queued ones are still written
+func (c *sinkConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.queue != nil {
+		close(c.queue)
+	}
+	return nil
+}
+
+type syslogSink struct {
+	sinkConn
+	facility int
+	hostname string
+	tag      string
+	pid      int
+}
+
+func newSyslogSink(p *Process, pid int, cfg SyslogConfig) *syslogSink {
+	var s = &syslogSink{
+		sinkConn: sinkConn{network: cfg.Network, address: cfg.Address},
+		facility: 1,
+		hostname: "-",
+		tag:      cfg.Tag,
+		pid:      pid,
+	}
+
+	if len(s.network) == 0 {
+		s.network = "unixgram"
+	}
+	if len(s.address) == 0 {
+		s.address = DefaultSyslogAddress
+	}
+	if facility, ok := syslogFacilities[strings.ToLower(cfg.Facility)]; ok {
+		s.facility = facility
+	}
+	if hostname, err := os.Hostname(); err == nil && len(hostname) > 0 {
+		s.hostname = hostname
+	}
+	if len(s.tag) == 0 {
+		s.tag = p.Name
+	}
+	s.tag = strings.ReplaceAll(s.tag, " ", "_")
+	if len(s.tag) > 48 {
+		s.tag = s.tag[:48]
+	}
+	return s
+}
+
+// format a RFC5424 message
+func (s *syslogSink) format(t time.Time, stream, line string) string {
+	var severity = severityInfo
+	if stream == StreamStderr {
+		severity = severityErr
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		s.facility*8+severity,
+		t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		s.hostname, s.tag, s.pid, stream, line)
+}
+
+func (s *syslogSink) send(t time.Time, stream, line string) {
+	msg := s.format(t, stream, line)
+
+	switch s.network {
+	case "tcp", "tcp4", "tcp6":
+		// octet counting framing of RFC6587
+		s.write([]byte(strconv.Itoa(len(msg)) + " " + msg))
+	case "unix":
+		s.write([]byte(msg + "\n"))
+	default:
+		s.write([]byte(msg))
+	}
+}
+
+// journaldSink sends the lines over the native journal protocol
+type journaldSink struct {
+	sinkConn
+	fields string
+}
+
+func newJournaldSink(p *Process, pid int) *journaldSink {
+	var fields = "SYSLOG_IDENTIFIER=" + p.Name + "\n" +
+		"PROCESS_NAME=" + p.Name + "\n" +
+		"PROCESS_PID=" + strconv.Itoa(pid) + "\n"
+	if len(p.Group) > 0 {
+		fields += "PROCESS_GROUP=" + p.Group + "\n" +
+			"PROCESS_INSTANCE=" + strconv.Itoa(p.Instance) + "\n"
+	}
+
+	return &journaldSink{
+		sinkConn: sinkConn{network: "unixgram", address: DefaultJournaldSocket},
+		fields:   fields,
+	}
+}
+
+func (s *journaldSink) format(stream, line string) []byte {
+	var priority = severityInfo
+	if stream == StreamStderr {
+		priority = severityErr
+	}
+
+	return []byte(s.fields +
+		"PRIORITY=" + strconv.Itoa(priority) + "\n" +
+		"PROCESS_STREAM=" + stream + "\n" +
+		"MESSAGE=" + line + "\n")
+}
+
+func (s *journaldSink) send(_ time.Time, stream, line string) {
+	s.write(s.format(stream, line))
+}
+
+// openSinks wraps the sinks configured by cfg for a run of p into the writers of
+// the stdout and stderr lines
+func (o *outputs) openSinks(p *Process, pid int, cfg LogConfig) (stdout, stderr []io.Writer) {
+	var sinks = make([]lineSink, 0)
+
+	if cfg.Syslog.Enabled() {
+		sinks = append(sinks, newSyslogSink(p, pid, cfg.Syslog))
+	}
+	if cfg.Journald {
+		sinks = append(sinks, newJournaldSink(p, pid))
+	}
+
+	for _, sink := range sinks {
+		sink := sink
+		stdoutW := newLineWriter(func(line string, _ bool) { sink.send(time.Now(), StreamStdout, line) })
+		stderrW := newLineWriter(func(line string, _ bool) { sink.send(time.Now(), StreamStderr, line) })
+		stdout = append(stdout, stdoutW)
+		stderr = append(stderr, stderrW)
+		o.lines = append(o.lines, stdoutW, stderrW)
+		o.sinks = append(o.sinks, sink)
+	}
+	return stdout, stderr
+}
@@ -0,0 +1,107 @@
+package process
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestSyslogSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	sink := newSyslogSink(&Process{Name: "api"}, 42, SyslogConfig{
+		Network:  "udp",
+		Address:  conn.LocalAddr().String(),
+		Facility: "local0",
+	})
+	defer sink.Close()
+
+	sink.send(time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC), StreamStderr, "boom")
+
+	var buf = make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "<131>1 2021-06-01T10:00:00.000000Z "+sink.hostname+" api 42 stderr - boom", string(buf[:n]))
+}
+
+func TestSyslogSink_Slow(t *testing.T) {
+	// accepts, but never reads
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	queueSize := SinkQueueSize
+	SinkQueueSize = 4
+	defer func() { SinkQueueSize = queueSize }()
+
+	sink := newSyslogSink(&Process{Name: "api"}, 42, SyslogConfig{Network: "tcp", Address: l.Addr().String()})
+	defer sink.Close()
+
+	var (
+		line  = strings.Repeat("x", 64*1024)
+		start = time.Now()
+	)
+	// more than the socket buffers take
+	for i := 0; i < 400; i++ {
+		sink.send(time.Now(), StreamStdout, line)
+	}
+	assert.True(t, time.Since(start) < time.Second)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.True(t, sink.dropped > 0)
+}
+
+func TestManager_JournaldSink(t *testing.T) {
+	dir, err := os.MkdirTemp("", "journal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	conn, err := net.ListenPacket("unixgram", socket)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	defaultSocket := DefaultJournaldSocket
+	DefaultJournaldSocket = socket
+	defer func() { DefaultJournaldSocket = defaultSocket }()
+
+	manager := NewManager(&ManagerConfig{WorkerDir: "./tmp"})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "echo to journal >&2"},
+		Dir:     "journald",
+		Restart: RestartPolicy{Mode: RestartNever},
+		Log:     LogConfig{Journald: true},
+	})
+	assert.NoError(t, err)
+	<-proc.done
+
+	var buf = make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(t, err)
+
+	fields := strings.Split(strings.TrimSpace(string(buf[:n])), "\n")
+	assert.Contains(t, fields, "PROCESS_NAME=sh")
+	assert.Contains(t, fields, "PRIORITY=3")
+	assert.Contains(t, fields, "MESSAGE=to journal")
+}
@@ -0,0 +1,185 @@
+package process
+
+import (
+	"sync"
+	"time"
+)
+
+// State lifecycle state of a managed process, tracked by the Manager
+type State string
+
+const (
+	StateUnknown  State = "unknown"
+	StateStarting State = "starting"
+	StateRunning  State = "running"
+	StateBackoff  State = "backoff"
+	StateStopping State = "stopping"
+	StateStopped  State = "stopped"
+	StateExited   State = "exited"
+	StateFatal    State = "fatal"
+)
+
+// stateMachine holds the runtime state of a process, it is shared by pointer
+// so copies of a Process report the same state
+type stateMachine struct {
+	mu          sync.Mutex
+	state       State
+	transitions map[State]time.Time
+	restarts    int
+	exit        *ExitStatus
+}
+
+func newStateMachine() *stateMachine {
+	return &stateMachine{
+		state:       StateUnknown,
+		transitions: map[State]time.Time{StateUnknown: time.Now()},
+	}
+}
+
+// ProcessStatus a snapshot of a managed process for the status API
+type ProcessStatus struct {
+	Name     string
+	Group    string
+	Binary   string
+	Args     []string
+	Dir      string
+	Pid      int32
+	State    State
+	Health   Health
+	StartAt  time.Time
+	Since    time.Time
+	Restarts int
+	// LastExit the exit of the last run, an adopted run, see
+	// RunRecord.Adopted, exits with code -1 as its real code is unknown
+	LastExit *ExitStatus
+	// Transitions the last time the process entered each state
+	Transitions map[State]time.Time
+	Children    []ProcessNode
+	// Cgroup the cgroup path of the process, when it has one
+	Cgroup string
+	// User the process runs as, empty for the manager user
+	User     string
+	Tuning   Tuning
+	Sandbox  Sandbox
+	Security Security
+}
+
+// State the lifecycle state of the process
+func (p *Process) State() State {
+	if p.state == nil {
+		return StateUnknown
+	}
+
+	p.state.mu.Lock()
+	defer p.state.mu.Unlock()
+	return p.state.state
+}
+
+func (p *Process) setState(state State) {
+	if p.state == nil {
+		return
+	}
+
+	p.state.mu.Lock()
+	defer p.state.mu.Unlock()
+	p.state.state = state
+	p.state.transitions[state] = time.Now()
+}
+
+// compareAndSetState moves to state only when the process is in old
+func (p *Process) compareAndSetState(old, state State) bool {
+	if p.state == nil {
+		return false
+	}
+
+	p.state.mu.Lock()
+	defer p.state.mu.Unlock()
+	if p.state.state != old {
+		return false
+	}
+	p.state.state = state
+	p.state.transitions[state] = time.Now()
+	return true
+}
+
+// setExited records the exit of a run, an exit after a stop request is stopped
+func (p *Process) setExited(status *ExitStatus) {
+	if p.state == nil {
+		return
+	}
+
+	p.state.mu.Lock()
+	defer p.state.mu.Unlock()
+	p.state.exit = status
+	if p.state.state == StateStopping {
+		p.state.state = StateStopped
+	} else {
+		p.state.state = StateExited
+	}
+	p.state.transitions[p.state.state] = time.Now()
+}
+
+func (p *Process) incRestarts() {
+	if p.state == nil {
+		return
+	}
+
+	p.state.mu.Lock()
+	defer p.state.mu.Unlock()
+	p.state.restarts++
+}
+
+// LastExit the exit status of the last finished run, nil if it never exited
+func (p *Process) LastExit() *ExitStatus {
+	if p.state == nil {
+		return nil
+	}
+
+	p.state.mu.Lock()
+	defer p.state.mu.Unlock()
+	return p.state.exit
+}
+
+// Snapshot the typed status of the process
+func (p *Process) Snapshot() ProcessStatus {
+	var status = ProcessStatus{
+		Name:     p.Name,
+		Group:    p.Group,
+		Binary:   p.Binary,
+		Args:     p.Args,
+		Dir:      p.Dir,
+		State:    StateUnknown,
+		Health:   p.Health(),
+		Children: p.Descendants(),
+		User:     p.Credential.User,
+		Tuning:   p.Tuning,
+		Sandbox:  p.Sandbox,
+		Security: p.Security,
+	}
+
+	if proc := p.handle(); proc != nil {
+		status.Pid = proc.Pid
+		status.StartAt = p.StartAt()
+	}
+
+	if p.cgroup != nil {
+		p.cgroup.mu.Lock()
+		status.Cgroup = p.cgroup.path
+		p.cgroup.mu.Unlock()
+	}
+
+	if p.state != nil {
+		p.state.mu.Lock()
+		defer p.state.mu.Unlock()
+		status.State = p.state.state
+		status.Since = p.state.transitions[p.state.state]
+		status.Restarts = p.state.restarts
+		status.LastExit = p.state.exit
+		status.Transitions = make(map[State]time.Time, len(p.state.transitions))
+		for state, t := range p.state.transitions {
+			status.Transitions[state] = t
+		}
+	}
+
+	return status
+}
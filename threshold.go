@@ -0,0 +1,171 @@
+package process
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hysios/log"
+	"github.com/hysios/utils/convert"
+	"go.uber.org/atomic"
+)
+
+// DefaultThresholdWindow how long the cpu usage must stay over MaxCPUPercent
+var DefaultThresholdWindow = time.Minute
+
+const (
+	// ThresholdRestart restarts the process gracefully
+	ThresholdRestart = "restart"
+	// ThresholdKill kills the process group, the restart policy applies after
+	ThresholdKill = "kill"
+	// ThresholdEvent only emits the event
+	ThresholdEvent = "event"
+)
+
+// Thresholds resource usage limits checked against the metrics samples
+type Thresholds struct {
+	// MaxMemory bytes of resident memory of the process tree, like "512M" in config
+	MaxMemory uint64
+	// MaxCPUPercent of one core used by the process tree for Window
+	MaxCPUPercent float64
+	// Window the cpu usage must stay over MaxCPUPercent, DefaultThresholdWindow by default
+	Window time.Duration
+	// Action ThresholdRestart, ThresholdKill or ThresholdEvent, ThresholdRestart by default
+	Action string
+}
+
+func (t Thresholds) WithDefaults() Thresholds {
+	if t.Window <= 0 {
+		t.Window = DefaultThresholdWindow
+	}
+	if len(t.Action) == 0 {
+		t.Action = ThresholdRestart
+	}
+	return t
+}
+
+func (t Thresholds) validate() error {
+	switch t.Action {
+	case "", ThresholdRestart, ThresholdKill, ThresholdEvent:
+		return nil
+	default:
+		return fmt.Errorf("unknown threshold action %s", t.Action)
+	}
+}
+
+func loadThresholds(v interface{}) Thresholds {
+	var t Thresholds
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return t
+	}
+
+	t.MaxMemory = toBytes(pm["MaxMemory"])
+	t.MaxCPUPercent, _ = convert.Float(pm["MaxCPUPercent"])
+	t.Window = toDuration(pm["Window"])
+	t.Action, _ = pm["Action"].(string)
+
+	return t
+}
+
+// thresholdState tracks a process going over its thresholds, an action is
+// taken once each time a threshold is crossed
+type thresholdState struct {
+	mu         sync.Mutex
+	pid        int32
+	memoryOver bool
+	cpuSince   time.Time
+	cpuOver    bool
+	// restarting while a threshold restart is in flight
+	restarting atomic.Bool
+}
+
+// check sample against t, the reason to act on is returned once per crossing
+func (s *thresholdState) check(t Thresholds, sample MetricsSample) (reason, detail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pid != sample.Pid {
+		// a new run
+		s.pid, s.memoryOver, s.cpuSince, s.cpuOver = sample.Pid, false, time.Time{}, false
+	}
+
+	if t.MaxMemory > 0 {
+		over := sample.RSS > t.MaxMemory
+		if over && !s.memoryOver {
+			reason = ExitReasonMemory
+			detail = fmt.Sprintf("rss %d bytes over %d", sample.RSS, t.MaxMemory)
+		}
+		s.memoryOver = over
+	}
+
+	if t.MaxCPUPercent > 0 {
+		if sample.CPUPercent <= t.MaxCPUPercent {
+			s.cpuSince, s.cpuOver = time.Time{}, false
+		} else if s.cpuSince.IsZero() {
+			s.cpuSince = sample.Time
+		} else if !s.cpuOver && sample.Time.Sub(s.cpuSince) >= t.Window {
+			s.cpuOver = true
+			if len(reason) == 0 {
+				reason = ExitReasonCPU
+				detail = fmt.Sprintf("cpu %.1f%% over %.1f%% for %s", sample.CPUPercent, t.MaxCPUPercent, t.Window)
+			}
+		}
+	}
+
+	return reason, detail
+}
+
+// checkThresholds acts on p going over its thresholds
+func (m *Manager) checkThresholds(p *Process) {
+	var t = p.Thresholds.WithDefaults()
+	if t.MaxMemory == 0 && t.MaxCPUPercent <= 0 || p.thresholds == nil {
+		return
+	}
+	if p.State() != StateRunning {
+		return
+	}
+
+	sample := p.LastSample()
+	if sample == nil {
+		return
+	}
+
+	reason, detail := p.thresholds.check(t, *sample)
+	if len(reason) == 0 {
+		return
+	}
+
+	log.Infof("process %s %s, %s", p.Name, detail, t.Action)
+	m.emit(Event{
+		Process: p.Name,
+		Trigger: reason,
+		Action:  t.Action,
+		Line:    detail,
+	})
+
+	var cmd = p.command()
+	switch t.Action {
+	case ThresholdRestart:
+		if p.daemon.Load() == 0 || !p.thresholds.restarting.CAS(false, true) {
+			return
+		}
+		// the restart waits for the run to exit, keep it off the sampler
+		go func() {
+			defer p.thresholds.restarting.Store(false)
+			if err := m.restartFor(p, reason); err != nil {
+				log.Errorf("restart process %s error %s", p.Name, err)
+			}
+		}()
+	case ThresholdKill:
+		if cmd.Process == nil {
+			return
+		}
+		p.setExitReason(reason)
+		if err := signalGroup(cmd.Process.Pid, syscall.SIGKILL); err != nil {
+			log.Errorf("kill process %s error %s", p.Name, err)
+		}
+	}
+}
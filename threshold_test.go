@@ -0,0 +1,106 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestParseBytes(t *testing.T) {
+	for s, n := range map[string]uint64{
+		"4096":   4096,
+		"512M":   512 << 20,
+		"1.5GiB": 3 << 29,
+		"64 kb":  64 << 10,
+	} {
+		v, err := parseBytes(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, n, v, s)
+	}
+
+	_, err := parseBytes("lots")
+	assert.Error(t, err)
+}
+
+func TestThresholdState_Check(t *testing.T) {
+	var (
+		state      = new(thresholdState)
+		thresholds = Thresholds{MaxMemory: 100, MaxCPUPercent: 50, Window: time.Minute}.WithDefaults()
+		now        = time.Now()
+	)
+
+	reason, _ := state.check(thresholds, MetricsSample{Pid: 1, Time: now, RSS: 200})
+	assert.Equal(t, ExitReasonMemory, reason)
+	// once per crossing
+	reason, _ = state.check(thresholds, MetricsSample{Pid: 1, Time: now, RSS: 200})
+	assert.Empty(t, reason)
+	state.check(thresholds, MetricsSample{Pid: 1, Time: now, RSS: 50})
+	reason, _ = state.check(thresholds, MetricsSample{Pid: 1, Time: now, RSS: 200})
+	assert.Equal(t, ExitReasonMemory, reason)
+
+	// cpu has to stay over for the window
+	reason, _ = state.check(thresholds, MetricsSample{Pid: 2, Time: now, CPUPercent: 90})
+	assert.Empty(t, reason)
+	reason, _ = state.check(thresholds, MetricsSample{Pid: 2, Time: now.Add(30 * time.Second), CPUPercent: 90})
+	assert.Empty(t, reason)
+	reason, _ = state.check(thresholds, MetricsSample{Pid: 2, Time: now.Add(40 * time.Second), CPUPercent: 10})
+	assert.Empty(t, reason)
+	state.check(thresholds, MetricsSample{Pid: 2, Time: now.Add(50 * time.Second), CPUPercent: 90})
+	reason, _ = state.check(thresholds, MetricsSample{Pid: 2, Time: now.Add(110 * time.Second), CPUPercent: 90})
+	assert.Equal(t, ExitReasonCPU, reason)
+}
+
+func TestManager_MaxMemory(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir:       "./tmp",
+		MetricsInterval: 50 * time.Millisecond,
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:       "sleep",
+		Args:       []string{"10"},
+		Dir:        "threshold",
+		Restart:    RestartPolicy{Mode: RestartOnFailure, Delay: 10 * time.Millisecond},
+		Thresholds: Thresholds{MaxMemory: 1, Action: ThresholdKill},
+	})
+	assert.NoError(t, err)
+	defer manager.StopProcessWait("sleep")
+
+	time.Sleep(300 * time.Millisecond)
+	history := proc.History()
+	assert.NotEmpty(t, history)
+	assert.Equal(t, ExitReasonMemory, history[len(history)-1].Exit.Reason)
+	assert.Equal(t, "SIGKILL", history[len(history)-1].Exit.Signal)
+}
+
+func TestManager_MaxMemoryRestart(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir:       "./tmp",
+		MetricsInterval: 50 * time.Millisecond,
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:        "sh",
+		Args:        []string{"-c", "trap '' TERM; sleep 10"},
+		Dir:         "threshold-restart",
+		StopSignal:  "SIGTERM",
+		StopTimeout: 300 * time.Millisecond,
+		Thresholds:  Thresholds{MaxMemory: 1, Action: ThresholdRestart},
+	})
+	assert.NoError(t, err)
+	defer manager.StopProcessWait("sh")
+
+	// the restart waits for the stop timeout off the sampler
+	time.Sleep(150 * time.Millisecond)
+	assert.True(t, proc.thresholds.restarting.Load())
+
+	time.Sleep(400 * time.Millisecond)
+	history := proc.History()
+	assert.NotEmpty(t, history)
+	assert.Equal(t, ExitReasonMemory, history[0].Exit.Reason)
+}
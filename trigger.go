@@ -0,0 +1,268 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hysios/log"
+	"github.com/hysios/utils/convert"
+)
+
+var (
+	// DefaultTriggerCooldown the least time between two firings of a trigger
+	DefaultTriggerCooldown = 30 * time.Second
+	// DefaultTriggerTimeout how long the command of a trigger may run
+	DefaultTriggerTimeout = time.Minute
+)
+
+const (
+	// TriggerRestart restarts the process
+	TriggerRestart = "restart"
+	// TriggerStop stops the process
+	TriggerStop = "stop"
+	// TriggerReady marks the process ready, a process with such a trigger and
+	// no health check stays starting until the line shows up
+	TriggerReady = "ready"
+	// TriggerEvent only emits the event
+	TriggerEvent = "event"
+	// TriggerCommand runs Command
+	TriggerCommand = "command"
+)
+
+// Trigger reacts to the output lines of a process matching Pattern, every
+// firing emits an event
+type Trigger struct {
+	// Name of the events, the pattern by default
+	Name    string
+	Pattern string
+	// Stream only lines of this stream are matched, both when empty
+	Stream string
+	Action string
+	// Command run by TriggerCommand in the process dir, with the matched line
+	// in the TRIGGER_LINE env
+	Command []string
+	// Timeout kills Command when it runs longer, DefaultTriggerTimeout by default
+	Timeout time.Duration
+	// Cooldown the least time between two firings, DefaultTriggerCooldown by
+	// default, a ready trigger fires on the first match of every run
+	Cooldown time.Duration
+}
+
+func loadTriggers(v interface{}) []Trigger {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var triggers = make([]Trigger, 0, len(items))
+	for _, item := range items {
+		pm, ok := convert.Map(item)
+		if !ok {
+			continue
+		}
+
+		var trigger Trigger
+		trigger.Name, _ = pm["Name"].(string)
+		trigger.Pattern, _ = pm["Pattern"].(string)
+		trigger.Stream, _ = pm["Stream"].(string)
+		trigger.Action, _ = pm["Action"].(string)
+		trigger.Command, _ = convert.SliceString(pm["Command"])
+		trigger.Timeout = toDuration(pm["Timeout"])
+		trigger.Cooldown = toDuration(pm["Cooldown"])
+		triggers = append(triggers, trigger)
+	}
+	return triggers
+}
+
+// triggerSet the compiled triggers of a process and when they last fired,
+// kept across runs so a crash loop does not reset the cooldowns
+type triggerSet struct {
+	mu       sync.Mutex
+	triggers []Trigger
+	patterns []*regexp.Regexp
+	fired    []time.Time
+}
+
+func newTriggerSet(triggers []Trigger) (*triggerSet, error) {
+	var set = &triggerSet{
+		triggers: triggers,
+		patterns: make([]*regexp.Regexp, len(triggers)),
+		fired:    make([]time.Time, len(triggers)),
+	}
+
+	for i, trigger := range triggers {
+		switch trigger.Action {
+		case TriggerRestart, TriggerStop, TriggerReady, TriggerEvent:
+		case TriggerCommand:
+			if len(trigger.Command) == 0 {
+				return nil, fmt.Errorf("trigger %s: command is missing", trigger.Pattern)
+			}
+		default:
+			return nil, fmt.Errorf("trigger %s: unknown action %s", trigger.Pattern, trigger.Action)
+		}
+
+		re, err := regexp.Compile(trigger.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %s: %w", trigger.Pattern, err)
+		}
+		set.patterns[i] = re
+	}
+	return set, nil
+}
+
+// match the triggers firing on line, each is held back for its cooldown after
+func (s *triggerSet) match(stream, line string, now time.Time) []Trigger {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched = make([]Trigger, 0)
+	for i, trigger := range s.triggers {
+		if len(trigger.Stream) > 0 && trigger.Stream != stream {
+			continue
+		}
+		if !s.patterns[i].MatchString(line) {
+			continue
+		}
+
+		cooldown := trigger.Cooldown
+		if cooldown <= 0 {
+			cooldown = DefaultTriggerCooldown
+		}
+		if !s.fired[i].IsZero() && now.Sub(s.fired[i]) < cooldown {
+			continue
+		}
+		s.fired[i] = now
+		matched = append(matched, trigger)
+	}
+	return matched
+}
+
+// newRun clears the cooldown of the ready triggers, each run has to become ready
+func (s *triggerSet) newRun() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, trigger := range s.triggers {
+		if trigger.Action == TriggerReady {
+			s.fired[i] = time.Time{}
+		}
+	}
+}
+
+// hasReady whether a trigger marks the process ready
+func (s *triggerSet) hasReady() bool {
+	if s == nil {
+		return false
+	}
+
+	for _, trigger := range s.triggers {
+		if trigger.Action == TriggerReady {
+			return true
+		}
+	}
+	return false
+}
+
+// openTriggers the writers matching the stdout and stderr lines of the run
+// of pproc by cmd against its triggers
+func (m *Manager) openTriggers(pproc *Process, cmd *exec.Cmd, out *outputs) (stdout, stderr []io.Writer) {
+	if pproc.triggers == nil || len(pproc.triggers.triggers) == 0 {
+		return nil, nil
+	}
+
+	pproc.triggers.newRun()
+	for _, stream := range []string{StreamStdout, StreamStderr} {
+		stream := stream
+		w := newLineWriter(func(line string, _ bool) {
+			for _, trigger := range pproc.triggers.match(stream, line, time.Now()) {
+				m.fire(pproc, cmd, trigger, stream, line)
+			}
+		})
+		out.lines = append(out.lines, w)
+		if stream == StreamStdout {
+			stdout = append(stdout, w)
+		} else {
+			stderr = append(stderr, w)
+		}
+	}
+	return stdout, stderr
+}
+
+// fire runs the action of trigger for a line of the run of pproc by cmd, the
+// actions waiting on the process run apart from the output copying
+func (m *Manager) fire(pproc *Process, cmd *exec.Cmd, trigger Trigger, stream, line string) {
+	var name = trigger.Name
+	if len(name) == 0 {
+		name = trigger.Pattern
+	}
+
+	log.Infof("process %s trigger %s fired, %s", pproc.Name, name, trigger.Action)
+	m.emit(Event{
+		Process: pproc.Name,
+		Trigger: name,
+		Action:  trigger.Action,
+		Stream:  stream,
+		Line:    line,
+	})
+
+	switch trigger.Action {
+	case TriggerRestart:
+		go func() {
+			if !pproc.isCurrent(cmd) || pproc.daemon.Load() == 0 {
+				return
+			}
+			if err := m.restartFor(pproc, ExitReasonTriggered); err != nil {
+				log.Errorf("restart process %s error %s", pproc.Name, err)
+			}
+		}()
+	case TriggerStop:
+		go func() {
+			if !pproc.isCurrent(cmd) {
+				return
+			}
+			if _, err := m.stopFor(pproc, ExitReasonTriggered); err != nil {
+				log.Errorf("stop process %s error %s", pproc.Name, err)
+			}
+		}()
+	case TriggerReady:
+		if pproc.isCurrent(cmd) && len(pproc.HealthCheck.Kind()) == 0 {
+			pproc.health.Store(string(HealthHealthy))
+			pproc.compareAndSetState(StateStarting, StateRunning)
+		}
+	case TriggerCommand:
+		go func() {
+			var timeout = trigger.Timeout
+			if timeout <= 0 {
+				timeout = DefaultTriggerTimeout
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			c := exec.CommandContext(ctx, trigger.Command[0], trigger.Command[1:]...)
+			c.Dir = cmd.Dir
+			// kill the children of the command too, and stop waiting on an
+			// output held open by one which got away
+			c.SysProcAttr = &syscall.SysProcAttr{}
+			setProcessGroup(c.SysProcAttr)
+			c.Cancel = func() error {
+				return signalGroup(c.Process.Pid, syscall.SIGKILL)
+			}
+			c.WaitDelay = time.Second
+			c.Env = append(append([]string(nil), cmd.Env...),
+				"TRIGGER_PROCESS="+pproc.Name,
+				"TRIGGER_NAME="+name,
+				"TRIGGER_STREAM="+stream,
+				"TRIGGER_LINE="+line,
+			)
+			if out, err := c.CombinedOutput(); err != nil {
+				log.Errorf("trigger %s of %s command error %s: %s", name, pproc.Name, err, out)
+			}
+		}()
+	}
+}
@@ -0,0 +1,132 @@
+package process
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tj/assert"
+)
+
+func TestTriggerSet_Match(t *testing.T) {
+	_, err := newTriggerSet([]Trigger{{Pattern: "x", Action: "explode"}})
+	assert.Error(t, err)
+
+	set, err := newTriggerSet([]Trigger{
+		{Pattern: "out of memory", Stream: StreamStderr, Action: TriggerRestart, Cooldown: time.Minute},
+		{Pattern: "^listening on", Action: TriggerEvent},
+	})
+	assert.NoError(t, err)
+
+	var now = time.Now()
+	assert.Len(t, set.match(StreamStdout, "out of memory", now), 0)
+	assert.Len(t, set.match(StreamStderr, "fatal: out of memory", now), 1)
+	// held back by the cooldown
+	assert.Len(t, set.match(StreamStderr, "fatal: out of memory", now.Add(time.Second)), 0)
+	assert.Len(t, set.match(StreamStderr, "fatal: out of memory", now.Add(2*time.Minute)), 1)
+	assert.Len(t, set.match(StreamStdout, "listening on :80", now), 1)
+
+	set, err = newTriggerSet([]Trigger{{Pattern: "^listening on", Action: TriggerReady}})
+	assert.NoError(t, err)
+	assert.Len(t, set.match(StreamStdout, "listening on :80", now), 1)
+	assert.Len(t, set.match(StreamStdout, "listening on :80", now), 0)
+	// fires again in the next run
+	set.newRun()
+	assert.Len(t, set.match(StreamStdout, "listening on :80", now), 1)
+}
+
+func TestManager_Triggers(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	_, since := manager.Events(0, 0)
+	proc, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "sleep 0.2; echo listening on 80; echo out of memory >&2; sleep 10"},
+		Dir:     "trigger",
+		Restart: RestartPolicy{Mode: RestartAlways, Delay: 10 * time.Millisecond},
+		Triggers: []Trigger{
+			{Name: "up", Pattern: "^listening on", Action: TriggerReady},
+			{Name: "oom", Pattern: "out of memory", Stream: StreamStderr, Action: TriggerRestart, Cooldown: time.Hour},
+		},
+	})
+	assert.NoError(t, err)
+	defer manager.StopProcessWait("sh")
+
+	// starting until the ready line
+	assert.Equal(t, StateStarting, proc.State())
+	assert.NoError(t, manager.waitReady("sh", 2*time.Second))
+
+	// restarted once, the second oom line is held back by the cooldown
+	time.Sleep(time.Second)
+	history := proc.History()
+	assert.NotEmpty(t, history)
+	assert.Equal(t, ExitReasonTriggered, history[len(history)-1].Exit.Reason)
+	assert.Equal(t, StateRunning, proc.State())
+
+	events, _ := manager.Events(since, 0)
+	var fired = make([]string, 0)
+	for _, event := range events {
+		if event.Process == "sh" {
+			fired = append(fired, event.Trigger)
+		}
+	}
+	assert.ElementsMatch(t, []string{"up", "oom", "up"}, fired)
+}
+
+func TestManager_TriggerStop(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:     "sh",
+		Args:     []string{"-c", "echo shutting down; sleep 10"},
+		Dir:      "trigger-stop",
+		Triggers: []Trigger{{Pattern: "shutting down", Action: TriggerStop}},
+	})
+	assert.NoError(t, err)
+	<-proc.done
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, StateStopped, proc.State())
+	assert.Equal(t, ExitReasonTriggered, proc.LastExit().Reason)
+	// not taken as stopped by the user
+	assert.False(t, proc.stopped())
+}
+
+func TestManager_TriggerCommandTimeout(t *testing.T) {
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	os.RemoveAll("./tmp/trigger-command")
+	proc, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "echo fire; touch fired"},
+		Dir:     "trigger-command",
+		Restart: RestartPolicy{Mode: RestartNever},
+		Triggers: []Trigger{{
+			Pattern: "fire",
+			Action:  TriggerCommand,
+			Command: []string{"sh", "-c", "(sleep 0.5; touch late) & wait"},
+			Timeout: 50 * time.Millisecond,
+		}},
+	})
+	assert.NoError(t, err)
+	<-proc.done
+
+	// killed before it got to the end
+	time.Sleep(time.Second)
+	_, err = os.Stat("./tmp/trigger-command/late")
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat("./tmp/trigger-command/fired")
+	assert.NoError(t, err)
+}
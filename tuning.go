@@ -0,0 +1,113 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hysios/utils/convert"
+)
+
+// RlimitInfinity an unlimited rlimit, "unlimited" in config
+const RlimitInfinity = ^uint64(0)
+
+// io scheduling classes of ionice
+var ioClasses = map[string]int{
+	"realtime":    1,
+	"best-effort": 2,
+	"idle":        3,
+}
+
+// Rlimit a resource limit of a process
+type Rlimit struct {
+	// Resource "nofile", "nproc", "core" or "as"
+	Resource string
+	Soft     uint64
+	// Hard the ceiling of Soft, Soft when lower
+	Hard uint64
+}
+
+// Tuning the scheduling and resource knobs of a process, the sandbox init
+// applies them in the child before the exec of the process, see SandboxMain,
+// zero fields leave the inherited values
+type Tuning struct {
+	Rlimits []Rlimit
+	// Nice -20 to 19
+	Nice int
+	// IOClass "realtime", "best-effort" or "idle", with IOPriority 0 to 7
+	IOClass    string
+	IOPriority int
+	// OOMScoreAdj -1000 to 1000
+	OOMScoreAdj int
+	// CPUs the cpus the process may run on
+	CPUs []int
+}
+
+func (t Tuning) empty() bool {
+	return len(t.Rlimits) == 0 && t.Nice == 0 && len(t.IOClass) == 0 && t.OOMScoreAdj == 0 && len(t.CPUs) == 0
+}
+
+func (t Tuning) validate() error {
+	for _, rlimit := range t.Rlimits {
+		if _, ok := rlimitResources[strings.ToLower(rlimit.Resource)]; !ok {
+			return fmt.Errorf("unknown rlimit %s", rlimit.Resource)
+		}
+	}
+	if t.Nice < -20 || t.Nice > 19 {
+		return fmt.Errorf("nice %d out of -20..19", t.Nice)
+	}
+	if len(t.IOClass) > 0 {
+		if _, ok := ioClasses[t.IOClass]; !ok {
+			return fmt.Errorf("unknown io class %s", t.IOClass)
+		}
+	}
+	if t.IOPriority < 0 || t.IOPriority > 7 {
+		return fmt.Errorf("io priority %d out of 0..7", t.IOPriority)
+	}
+	if t.OOMScoreAdj < -1000 || t.OOMScoreAdj > 1000 {
+		return fmt.Errorf("oom score adj %d out of -1000..1000", t.OOMScoreAdj)
+	}
+	for _, cpu := range t.CPUs {
+		if cpu < 0 {
+			return fmt.Errorf("invalid cpu %d", cpu)
+		}
+	}
+	return nil
+}
+
+func loadTuning(v interface{}) Tuning {
+	var t Tuning
+
+	pm, ok := convert.Map(v)
+	if !ok {
+		return t
+	}
+
+	if items, ok := pm["Rlimits"].([]interface{}); ok {
+		for _, item := range items {
+			rm, ok := convert.Map(item)
+			if !ok {
+				continue
+			}
+
+			var rlimit Rlimit
+			rlimit.Resource, _ = rm["Resource"].(string)
+			rlimit.Soft = toRlimitValue(rm["Soft"])
+			rlimit.Hard = toRlimitValue(rm["Hard"])
+			t.Rlimits = append(t.Rlimits, rlimit)
+		}
+	}
+	t.Nice, _ = convert.Int(pm["Nice"])
+	t.IOClass, _ = pm["IOClass"].(string)
+	t.IOPriority, _ = convert.Int(pm["IOPriority"])
+	t.OOMScoreAdj, _ = convert.Int(pm["OOMScoreAdj"])
+	t.CPUs, _ = convert.SliceInt(pm["CPUs"])
+
+	return t
+}
+
+func toRlimitValue(v interface{}) uint64 {
+	if s, ok := v.(string); ok && s == "unlimited" {
+		return RlimitInfinity
+	}
+	return toBytes(v)
+}
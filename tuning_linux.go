@@ -0,0 +1,76 @@
+package process
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const ioprioWhoProcess = 1
+
+// rlimitResources the rlimits which can be set on a process
+var rlimitResources = map[string]int{
+	"nofile": syscall.RLIMIT_NOFILE,
+	"nproc":  unix.RLIMIT_NPROC,
+	"core":   syscall.RLIMIT_CORE,
+	"as":     syscall.RLIMIT_AS,
+}
+
+// apply t to the calling thread, which execs the process, nice, io priority
+// and cpu affinity are per thread on linux
+func (t Tuning) apply() error {
+	// 0 is the caller in the syscalls below
+	const pid = 0
+
+	for _, rlimit := range t.Rlimits {
+		var limit = syscall.Rlimit{Cur: rlimit.Soft, Max: rlimit.Hard}
+		if limit.Max < limit.Cur {
+			limit.Max = limit.Cur
+		}
+
+		resource := rlimitResources[strings.ToLower(rlimit.Resource)]
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&limit)), 0, 0, 0)
+		if errno != 0 {
+			return fmt.Errorf("rlimit %s: %w", rlimit.Resource, errno)
+		}
+	}
+
+	if t.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, t.Nice); err != nil {
+			return fmt.Errorf("nice: %w", err)
+		}
+	}
+
+	if len(t.IOClass) > 0 {
+		prio := ioClasses[t.IOClass]<<13 | t.IOPriority
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+			return fmt.Errorf("ionice: %w", errno)
+		}
+	}
+
+	if t.OOMScoreAdj != 0 {
+		if err := os.WriteFile("/proc/self/oom_score_adj", []byte(strconv.Itoa(t.OOMScoreAdj)), 0644); err != nil {
+			return fmt.Errorf("oom score adj: %w", err)
+		}
+	}
+
+	if len(t.CPUs) > 0 {
+		var mask = make([]uint64, 0)
+		for _, cpu := range t.CPUs {
+			for len(mask) <= cpu/64 {
+				mask = append(mask, 0)
+			}
+			mask[cpu/64] |= 1 << (uint(cpu) % 64)
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, uintptr(pid), uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0]))); errno != 0 {
+			return fmt.Errorf("cpu affinity: %w", errno)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package process
+
+import "errors"
+
+var rlimitResources = map[string]int{}
+
+// apply t to the calling thread
+func (t Tuning) apply() error {
+	return errors.New("process tuning is only supported on linux")
+}
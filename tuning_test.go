@@ -0,0 +1,77 @@
+package process
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/tj/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTuning_Validate(t *testing.T) {
+	assert.NoError(t, Tuning{Nice: 5, IOClass: "idle", OOMScoreAdj: 500}.validate())
+	assert.Error(t, Tuning{Nice: 40}.validate())
+	assert.Error(t, Tuning{IOClass: "fast"}.validate())
+	assert.Error(t, Tuning{OOMScoreAdj: 2000}.validate())
+	assert.Error(t, Tuning{Rlimits: []Rlimit{{Resource: "stack"}}}.validate())
+}
+
+func TestTuning_Load(t *testing.T) {
+	var tuning = Tuning{
+		Rlimits: []Rlimit{{Resource: "nofile", Soft: 1024, Hard: 4096}, {Resource: "core", Soft: RlimitInfinity}},
+		Nice:    5,
+		IOClass: "best-effort",
+		CPUs:    []int{0, 1},
+	}
+
+	b, err := yaml.Marshal(structs.Map(struct{ Tuning Tuning }{tuning}))
+	assert.NoError(t, err)
+
+	var mm map[string]interface{}
+	assert.NoError(t, yaml.Unmarshal(b, &mm))
+	assert.Equal(t, tuning, loadTuning(mm["Tuning"]))
+}
+
+func TestManager_Tuning(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("tuning is applied on linux only")
+	}
+
+	manager := NewManager(&ManagerConfig{
+		WorkerDir: "./tmp",
+	})
+	defer manager.Stop()
+	go manager.Run()
+
+	proc, err := manager.Start(StartReq{
+		Name:    "sh",
+		Args:    []string{"-c", "echo $(ulimit -n) $(cat /proc/self/oom_score_adj) $(nice); sleep 10"},
+		Dir:     "tuning",
+		Restart: RestartPolicy{Mode: RestartNever},
+		Tuning: Tuning{
+			Rlimits:     []Rlimit{{Resource: "nofile", Soft: 77, Hard: 77}},
+			Nice:        5,
+			OOMScoreAdj: 500,
+			CPUs:        []int{0},
+		},
+	})
+	assert.NoError(t, err)
+	defer manager.StopProcessWait("sh")
+	assert.Equal(t, 5, proc.Snapshot().Tuning.Nice)
+
+	// applied again to the restarted command
+	var since = uint64(1)
+	for run := 0; run < 2; run++ {
+		if run > 0 {
+			assert.NoError(t, manager.RestartProcess("sh"))
+		}
+
+		lines, next, err := manager.Logs("sh", since, 0, 2*time.Second)
+		assert.NoError(t, err)
+		assert.Len(t, lines, 1)
+		assert.Equal(t, "77 500 5", lines[0].Line)
+		since = next
+	}
+}
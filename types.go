@@ -2,6 +2,7 @@ package process
 
 import (
 	"encoding/gob"
+	"time"
 )
 
 type StartReq struct {
@@ -10,10 +11,93 @@ type StartReq struct {
 	Args   []string
 	Env    []string
 	Dir    string
+
+	EnvFile  []string
+	EnvMode  string
+	EnvAllow []string
+
+	Restart     RestartPolicy
+	StopSignal  string
+	StopTimeout time.Duration
+	HealthCheck HealthCheck
+
+	DependsOn           []string
+	RestartOnDependency bool
+
+	Instances int
+	Port      int
+
+	Log        LogConfig
+	Triggers   []Trigger
+	Thresholds Thresholds
+	Cgroup     CgroupConfig
+	Credential Credential
+	Tuning     Tuning
+	Sandbox    Sandbox
+	Security   Security
+}
+
+type ScaleReq struct {
+	Name      string
+	Instances int
+}
+
+type LogsReq struct {
+	Name  string
+	Lines int
+	// Since the seq of the first line wanted, 0 for the last Lines lines
+	Since uint64
+	// Wait how long the server waits for new output when there is none
+	Wait time.Duration
+}
+
+type LogsReply struct {
+	Lines []LogLine
+	Next  uint64
+}
+
+type SearchLogsReq struct {
+	Name string
+	// Stream StreamStdout or StreamStderr, both when empty
+	Stream string
+	// Since and Until bound the time of the lines, when not zero
+	Since time.Time
+	Until time.Time
+	// Pattern a regexp the lines must match
+	Pattern string
+	Offset  int
+	// Limit lines returned, DefaultSearchLimit when 0
+	Limit int
+}
+
+type SearchLogsReply struct {
+	Lines []LogLine
+	// Next the offset of the following page, 0 when there is none
+	Next int
+}
+
+type MetricsReq struct {
+	Name string
+	// Since the samples taken after since, all kept samples when zero
+	Since time.Time
+}
+
+type EventsReq struct {
+	// Since the seq of the first event wanted
+	Since uint64
+	// Wait how long the server waits for an event when there is none
+	Wait time.Duration
+}
+
+type EventsReply struct {
+	Events []Event
+	Next   uint64
 }
 
 func init() {
 	gob.Register(new(StartReq))
+	gob.Register(new(ScaleReq))
 	gob.Register(new(Process))
+	gob.Register([]ProcessNode{})
 
 }
@@ -1,6 +1,14 @@
 package process
 
-import "unicode"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hysios/utils/convert"
+)
 
 func sep(r rune) bool {
 	if unicode.IsSpace(r) {
@@ -48,3 +56,55 @@ func MakeSplitQuota() func(rune) bool {
 		return false
 	}
 }
+
+// toDuration converts config values such as "5s" or nanoseconds to time.Duration
+func toDuration(v interface{}) time.Duration {
+	if s, ok := v.(string); ok {
+		d, _ := time.ParseDuration(s)
+		return d
+	}
+
+	d, _ := convert.Duration(v)
+	return d
+}
+
+var byteUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// parseBytes parses a size like "512M", "1.5GiB" or "4096"
+func parseBytes(s string) (uint64, error) {
+	var (
+		upper = strings.ToUpper(strings.TrimSpace(s))
+		unit  = uint64(1)
+	)
+
+	for _, u := range byteUnits {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper, unit = strings.TrimSpace(strings.TrimSuffix(upper, u.suffix)), u.size
+			break
+		}
+	}
+
+	f, err := strconv.ParseFloat(upper, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %s", s)
+	}
+	return uint64(f * float64(unit)), nil
+}
+
+func toBytes(v interface{}) uint64 {
+	if s, ok := v.(string); ok {
+		n, _ := parseBytes(s)
+		return n
+	}
+
+	n, _ := convert.Uint64(v)
+	return n
+}